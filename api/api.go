@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/alethio/eth2stats-client/core"
+	"github.com/alethio/eth2stats-client/exporter"
+)
+
+var log = logrus.WithField("module", "api")
+
+type Config struct {
+	Addr              string
+	HeartbeatInterval time.Duration
+}
+
+// StatusProvider gives the latest status of a reported node.
+type StatusProvider interface {
+	Status() core.Status
+}
+
+// Server exposes the state of the client itself over HTTP, for humans and orchestrators alike.
+type Server struct {
+	config Config
+	nodes  []StatusProvider
+}
+
+type nodeHealth struct {
+	NodeName         string   `json:"nodeName"`
+	Healthy          bool     `json:"healthy"`
+	BeaconReachable  bool     `json:"beaconReachable"`
+	Connected        bool     `json:"connected"`
+	LastHeartbeatAge *float64 `json:"lastHeartbeatAge"`
+	// FinalityStalled and HeadLagging are about the chain rather than the client, so they don't make it unhealthy.
+	FinalityStalled bool `json:"finalityStalled"`
+	HeadLagging     bool `json:"headLagging"`
+}
+
+func New(config Config, nodes []StatusProvider) *Server {
+	return &Server{
+		config: config,
+		nodes:  nodes,
+	}
+}
+
+// Run serves the API until the context is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	router.GET("/health", s.health)
+	router.GET("/status", s.status)
+	router.GET("/live", s.live)
+	router.GET("/ready", s.ready)
+	router.GET("/metrics", gin.WrapH(exporter.Handler()))
+
+	server := &http.Server{
+		Addr:    s.config.Addr,
+		Handler: router,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.WithField("addr", s.config.Addr).Info("serving status api")
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) health(c *gin.Context) {
+	healthy := true
+	nodes := make([]nodeHealth, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		status := node.Status()
+		h := nodeHealth{
+			NodeName:        status.NodeName,
+			BeaconReachable: status.BeaconReachable,
+			Connected:       status.Connected,
+		}
+
+		if status.Finality != nil {
+			h.FinalityStalled = status.Finality.FinalityStalled
+			h.HeadLagging = status.Finality.HeadLagging
+		}
+
+		heartbeatOK := false
+		if status.LastHeartbeat != nil {
+			age := time.Since(*status.LastHeartbeat)
+			ageSeconds := age.Seconds()
+			h.LastHeartbeatAge = &ageSeconds
+			heartbeatOK = age < MissedHeartbeats*s.config.HeartbeatInterval
+		}
+		h.Healthy = h.BeaconReachable && h.Connected && heartbeatOK
+		healthy = healthy && h.Healthy
+
+		nodes = append(nodes, h)
+	}
+
+	code := http.StatusOK
+	if !healthy {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"healthy": healthy,
+		"nodes":   nodes,
+	})
+}
+
+func (s *Server) status(c *gin.Context) {
+	nodes := make([]core.Status, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node.Status())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nodes": nodes,
+	})
+}
+
+// live only tells whether the process is able to respond at all.
+func (s *Server) live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"live": true,
+	})
+}
+
+// ready tells whether every node is connected to the eth2stats server.
+func (s *Server) ready(c *gin.Context) {
+	ready := true
+	for _, node := range s.nodes {
+		ready = ready && node.Status().Connected
+	}
+
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"ready": ready,
+	})
+}
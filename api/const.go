@@ -0,0 +1,12 @@
+package api
+
+import (
+	"time"
+)
+
+const (
+	// A node is unhealthy once this many heartbeats in a row have been missed.
+	MissedHeartbeats = 3
+
+	ShutdownTimeout = 5 * time.Second
+)
@@ -1,7 +1,9 @@
 package beacon
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/alethio/eth2stats-client/types"
 )
@@ -9,18 +11,45 @@ import (
 // Error to use when a  call is not available
 var NotImplemented = errors.New("Feature is not available")
 
+// CallTimeout bounds a single call to a beacon node.
+const CallTimeout = 10 * time.Second
+
+// AttestationPoolSlots is how many recent slots GetRecentAttestationsInPoolCount counts the attestations in pool for.
+const AttestationPoolSlots = 2
+
+// MaxAttestationPoolBytes bounds how much of an attestation pool listing is read to count it,
+// as a safety net against nodes ignoring the slot filter.
+const MaxAttestationPoolBytes = 16 << 20
+
+// ChainHeadSubscription delivers new chain heads until it is closed or its context is cancelled.
 type ChainHeadSubscription interface {
 	Channel() <-chan types.ChainHead
 	Close()
 }
 
+// Client is a beacon node. Every call is aborted once its context is done.
 type Client interface {
-	GetVersion() (string, error)
-	GetGenesisTime() (int64, error)
-	GetPeerCount() (int64, error)
-	GetAttestationsInPoolCount() (int64, error)
-	GetSyncStatus() (bool, error)
-	GetChainHead() (*types.ChainHead, error)
-
-	SubscribeChainHeads() (ChainHeadSubscription, error)
+	GetVersion(ctx context.Context) (string, error)
+	GetGenesisTime(ctx context.Context) (int64, error)
+	// GetSpec returns NotImplemented if the node doesn't serve its chain configuration.
+	GetSpec(ctx context.Context) (*types.Spec, error)
+	GetPeerCount(ctx context.Context) (int64, error)
+	// GetPeers lists the peers of the node, including the ones that are not connected if the node tells.
+	GetPeers(ctx context.Context) ([]types.Peer, error)
+	// GetAttestationsInPoolCount counts the whole attestation pool of the node.
+	GetAttestationsInPoolCount(ctx context.Context) (int64, error)
+	// GetRecentAttestationsInPoolCount counts the attestations in pool of the last AttestationPoolSlots slots only,
+	// for nodes that can't tell the size of their whole pool cheaply.
+	GetRecentAttestationsInPoolCount(ctx context.Context) (int64, error)
+	GetSyncStatus(ctx context.Context) (bool, error)
+	GetChainHead(ctx context.Context) (*types.ChainHead, error)
+	// GetValidators returns the current state of the validators with the given indices or 0x-prefixed public keys.
+	GetValidators(ctx context.Context, ids []string) ([]types.Validator, error)
+	// GetValidatorPerformance evaluates validators in an epoch; the epoch after it has to be over.
+	GetValidatorPerformance(ctx context.Context, epoch uint64, indices []uint64) ([]types.ValidatorPerformance, error)
+
+	SubscribeChainHeads(ctx context.Context) (ChainHeadSubscription, error)
+
+	// Capabilities probes which of the optional calls the node supports.
+	Capabilities(ctx context.Context) (*Capabilities, error)
 }
@@ -0,0 +1,42 @@
+package beacon
+
+// Capabilities tells which of the optional calls a beacon node supports.
+// Calls that every node supports are not listed.
+type Capabilities struct {
+	AttestationsInPool       bool `json:"attestationsInPool"`
+	RecentAttestationsInPool bool `json:"recentAttestationsInPool"`
+	SyncStatus               bool `json:"syncStatus"`
+	Spec                     bool `json:"spec"`
+	Validators               bool `json:"validators"`
+}
+
+// Matrix lists every capability by name, for logging and reporting.
+func (c Capabilities) Matrix() map[string]bool {
+	return map[string]bool{
+		"attestationsInPool":       c.AttestationsInPool,
+		"recentAttestationsInPool": c.RecentAttestationsInPool,
+		"syncStatus":               c.SyncStatus,
+		"spec":                     c.Spec,
+		"validators":               c.Validators,
+	}
+}
+
+// Probe tells whether an optional call is supported from the error it returned.
+// Errors other than NotImplemented mean the node could not be asked.
+func Probe(err error) (bool, error) {
+	if err == NotImplemented {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Intersect returns the capabilities supported by both.
+func (c Capabilities) Intersect(other Capabilities) Capabilities {
+	return Capabilities{
+		AttestationsInPool:       c.AttestationsInPool && other.AttestationsInPool,
+		RecentAttestationsInPool: c.RecentAttestationsInPool && other.RecentAttestationsInPool,
+		SyncStatus:               c.SyncStatus && other.SyncStatus,
+		Spec:                     c.Spec && other.Spec,
+		Validators:               c.Validators && other.Validators,
+	}
+}
@@ -0,0 +1,321 @@
+package failover
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+var log = logrus.WithField("module", "failover")
+
+const (
+	HealthCheckInterval = 12 * time.Second
+	// MaxSlotLag is how many slots a backend may be behind the best one and still be used.
+	MaxSlotLag = 2
+)
+
+// Backend is one of the redundant beacon nodes, named after its address.
+type Backend struct {
+	Name   string
+	Client beacon.Client
+}
+
+type backendState struct {
+	Backend
+	healthy  bool
+	headSlot uint64
+}
+
+// Client routes every call to the first healthy backend that is in sync with the others,
+// in the order the backends were given, and fails over to the next one when a call fails.
+type Client struct {
+	mu       sync.RWMutex
+	backends []*backendState
+	active   int
+	// closed and replaced whenever the active backend changes
+	switched chan struct{}
+}
+
+// Check interface
+var _ = beacon.Client((*Client)(nil))
+
+// New assumes every backend is healthy until a call or health check says otherwise.
+func New(backends []Backend) *Client {
+	c := &Client{
+		switched: make(chan struct{}),
+	}
+	for _, b := range backends {
+		c.backends = append(c.backends, &backendState{Backend: b, healthy: true})
+	}
+	return c
+}
+
+// Active returns the name of the backend calls are currently routed to.
+func (c *Client) Active() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.backends[c.active].Name
+}
+
+// Run health-checks the backends every HealthCheckInterval until the context is cancelled.
+func (c *Client) Run(ctx context.Context) {
+	for {
+		c.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(HealthCheckInterval):
+		}
+	}
+}
+
+// Check asks every backend for its head and switches to the preferred backend among the healthy ones.
+func (c *Client) Check(ctx context.Context) {
+	type result struct {
+		head *types.ChainHead
+		err  error
+	}
+	results := make([]result, len(c.backends))
+	var wg sync.WaitGroup
+	for i, b := range c.backends {
+		wg.Add(1)
+		go func(i int, client beacon.Client) {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+			defer cancel()
+			head, err := client.GetChainHead(callCtx)
+			results[i] = result{head, err}
+		}(i, b.Client)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, b := range c.backends {
+		r := results[i]
+		if r.err != nil {
+			if b.healthy {
+				log.WithField("backend", b.Name).Warnf("backend is unhealthy: %s", r.err)
+			}
+			b.healthy = false
+			continue
+		}
+		if !b.healthy {
+			log.WithField("backend", b.Name).Info("backend is healthy again")
+		}
+		b.healthy = true
+		b.headSlot = r.head.HeadSlot
+	}
+	c.choose()
+}
+
+// fail marks a backend unhealthy after a failed call and switches away from it if it was active.
+// It returns whether there is another backend to try.
+func (c *Client) fail(i int, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.backends[i]
+	if b.healthy {
+		log.WithField("backend", b.Name).Warnf("backend is unhealthy: %s", err)
+	}
+	b.healthy = false
+	if c.active == i {
+		c.choose()
+	}
+	return c.active != i
+}
+
+// choose switches to the first healthy backend that is at most MaxSlotLag behind the best one.
+// Without healthy backends, the active backend is kept. It must be called with the lock held.
+func (c *Client) choose() {
+	var bestSlot uint64
+	anyHealthy := false
+	for _, b := range c.backends {
+		if b.healthy {
+			anyHealthy = true
+			if b.headSlot > bestSlot {
+				bestSlot = b.headSlot
+			}
+		}
+	}
+	if !anyHealthy {
+		return
+	}
+
+	for i, b := range c.backends {
+		if !b.healthy || b.headSlot+MaxSlotLag < bestSlot {
+			continue
+		}
+		if i != c.active {
+			log.WithFields(logrus.Fields{
+				"from":     c.backends[c.active].Name,
+				"to":       b.Name,
+				"headSlot": b.headSlot,
+			}).Warn("switching beacon node backend")
+			c.active = i
+			close(c.switched)
+			c.switched = make(chan struct{})
+		}
+		return
+	}
+}
+
+func (c *Client) current() (int, beacon.Client, <-chan struct{}) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.active, c.backends[c.active].Client, c.switched
+}
+
+// do makes a call on the active backend, failing over to the next ones until it succeeds.
+func (c *Client) do(ctx context.Context, call func(beacon.Client) error) error {
+	var err error
+	for range c.backends {
+		i, client, _ := c.current()
+		err = call(client)
+		if err == nil || err == beacon.NotImplemented || ctx.Err() != nil {
+			return err
+		}
+		if !c.fail(i, err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *Client) GetVersion(ctx context.Context) (version string, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		version, err = client.GetVersion(ctx)
+		return err
+	})
+	return version, err
+}
+
+func (c *Client) GetGenesisTime(ctx context.Context) (genesisTime int64, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		genesisTime, err = client.GetGenesisTime(ctx)
+		return err
+	})
+	return genesisTime, err
+}
+
+func (c *Client) GetSpec(ctx context.Context) (spec *types.Spec, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		spec, err = client.GetSpec(ctx)
+		return err
+	})
+	return spec, err
+}
+
+func (c *Client) GetPeerCount(ctx context.Context) (peers int64, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		peers, err = client.GetPeerCount(ctx)
+		return err
+	})
+	return peers, err
+}
+
+func (c *Client) GetPeers(ctx context.Context) (peers []types.Peer, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		peers, err = client.GetPeers(ctx)
+		return err
+	})
+	return peers, err
+}
+
+func (c *Client) GetAttestationsInPoolCount(ctx context.Context) (attestations int64, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		attestations, err = client.GetAttestationsInPoolCount(ctx)
+		return err
+	})
+	return attestations, err
+}
+
+func (c *Client) GetRecentAttestationsInPoolCount(ctx context.Context) (attestations int64, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		attestations, err = client.GetRecentAttestationsInPoolCount(ctx)
+		return err
+	})
+	return attestations, err
+}
+
+func (c *Client) GetSyncStatus(ctx context.Context) (syncing bool, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		syncing, err = client.GetSyncStatus(ctx)
+		return err
+	})
+	return syncing, err
+}
+
+func (c *Client) GetChainHead(ctx context.Context) (head *types.ChainHead, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		head, err = client.GetChainHead(ctx)
+		return err
+	})
+	return head, err
+}
+
+func (c *Client) GetValidators(ctx context.Context, ids []string) (validators []types.Validator, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		validators, err = client.GetValidators(ctx, ids)
+		return err
+	})
+	return validators, err
+}
+
+func (c *Client) GetValidatorPerformance(ctx context.Context, epoch uint64, indices []uint64) (performance []types.ValidatorPerformance, err error) {
+	err = c.do(ctx, func(client beacon.Client) error {
+		performance, err = client.GetValidatorPerformance(ctx, epoch, indices)
+		return err
+	})
+	return performance, err
+}
+
+// Capabilities are those supported by every backend that could be asked, so whichever
+// backend is active, calls aren't made that it doesn't support.
+func (c *Client) Capabilities(ctx context.Context) (*beacon.Capabilities, error) {
+	var capabilities *beacon.Capabilities
+	var lastErr error
+	for _, b := range c.backends {
+		backendCapabilities, err := b.Client.Capabilities(ctx)
+		if err != nil {
+			log.WithField("backend", b.Name).Warnf("probing capabilities: %s", err)
+			lastErr = err
+			continue
+		}
+		if capabilities == nil {
+			capabilities = backendCapabilities
+		} else {
+			intersection := capabilities.Intersect(*backendCapabilities)
+			capabilities = &intersection
+		}
+	}
+	if capabilities == nil {
+		return nil, lastErr
+	}
+	return capabilities, nil
+}
+
+func (c *Client) SubscribeChainHeads(ctx context.Context) (beacon.ChainHeadSubscription, error) {
+	s := newSubscription(ctx, c)
+	sub, backend, switched, err := s.subscribe()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	go s.run(sub, backend, switched)
+
+	return s, nil
+}
@@ -0,0 +1,117 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// ResubscribeInterval is how long to wait before subscribing again when no backend accepted a subscription.
+const ResubscribeInterval = 5 * time.Second
+
+// subscription follows the chain heads of the active backend,
+// moving over to the new one whenever the active backend changes.
+type subscription struct {
+	client *Client
+	data   chan types.ChainHead
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Check interface
+var _ = beacon.ChainHeadSubscription((*subscription)(nil))
+
+func newSubscription(ctx context.Context, client *Client) *subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	return &subscription{
+		client: client,
+		data:   make(chan types.ChainHead),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (s *subscription) run(sub beacon.ChainHeadSubscription, backend int, switched <-chan struct{}) {
+	defer close(s.data)
+
+	for {
+		ended := !s.forward(sub, switched)
+		sub.Close()
+		if s.ctx.Err() != nil {
+			return
+		}
+		if ended {
+			s.client.fail(backend, errors.New("chain head subscription closed"))
+		}
+
+		var err error
+		for {
+			sub, backend, switched, err = s.subscribe()
+			if err == nil {
+				break
+			}
+			if s.ctx.Err() != nil {
+				return
+			}
+			log.Errorf("no backend to subscribe to chain heads: %s", err)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(ResubscribeInterval):
+			}
+		}
+		log.WithField("backend", s.client.Active()).Info("moved chain head subscription")
+	}
+}
+
+// subscribe subscribes to the active backend, failing over to the next ones until one accepts.
+// It also returns the channel that tells when the backend is no longer the active one.
+func (s *subscription) subscribe() (beacon.ChainHeadSubscription, int, <-chan struct{}, error) {
+	var err error
+	for range s.client.backends {
+		i, client, switched := s.client.current()
+		var sub beacon.ChainHeadSubscription
+		sub, err = client.SubscribeChainHeads(s.ctx)
+		if err == nil {
+			return sub, i, switched, nil
+		}
+		if s.ctx.Err() != nil || !s.client.fail(i, err) {
+			break
+		}
+	}
+	return nil, 0, nil, err
+}
+
+// forward passes heads on until the subscription ends or is closed, which returns false,
+// or the active backend changes.
+func (s *subscription) forward(sub beacon.ChainHeadSubscription, switched <-chan struct{}) bool {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return false
+		case <-switched:
+			return true
+		case head, ok := <-sub.Channel():
+			if !ok {
+				return false
+			}
+			select {
+			case s.data <- head:
+			case <-s.ctx.Done():
+				return false
+			}
+		}
+	}
+}
+
+func (s *subscription) Channel() <-chan types.ChainHead {
+	return s.data
+}
+
+func (s *subscription) Close() {
+	s.cancel()
+}
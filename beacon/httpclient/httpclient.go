@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dghubble/sling"
+)
+
+// ErrTooLarge is returned when a response body is larger than allowed.
+var ErrTooLarge = errors.New("response body too large")
+
+// StatusError is returned when a response does not have a 2xx status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("responded with status code %d", e.StatusCode)
+}
+
+// ReceiveSuccess is sling's ReceiveSuccess, with the request bound to the given context.
+func ReceiveSuccess(ctx context.Context, s *sling.Sling, successV interface{}) (*http.Response, error) {
+	req, err := s.Request()
+	if err != nil {
+		return nil, err
+	}
+	return s.Do(req.WithContext(ctx), successV, nil)
+}
+
+// CountArray counts the elements of the JSON array under the given top-level field of the response,
+// or of the response itself if the field is empty. The elements are skipped token by token rather than
+// decoded, so memory stays bounded however long the array is; at most maxBytes of the body are read.
+func CountArray(ctx context.Context, client *http.Client, s *sling.Sling, field string, maxBytes int64) (int64, error) {
+	req, err := s.Request()
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	dec := json.NewDecoder(&limitedReader{r: resp.Body, n: maxBytes})
+	if field != "" {
+		found, err := seekField(dec, field)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			return 0, fmt.Errorf("no %q in response", field)
+		}
+	}
+
+	t, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		// nodes may list an empty array as null
+		return 0, nil
+	}
+	if t != json.Delim('[') {
+		return 0, fmt.Errorf("expected an array, got %v", t)
+	}
+	var count int64
+	for dec.More() {
+		if err := skipValue(dec); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// seekField moves the decoder to the value of the given field of the object it is at.
+func seekField(dec *json.Decoder, field string) (bool, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if t != json.Delim('{') {
+		return false, fmt.Errorf("expected an object, got %v", t)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		if key == field {
+			return true, nil
+		}
+		if err := skipValue(dec); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// skipValue reads past the next value without keeping it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// limitedReader is io.LimitReader, but fails with ErrTooLarge rather than ending the body early.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// a body of exactly the limit still ends properly
+		var b [1]byte
+		if n, err := l.r.Read(b[:]); n == 0 && err == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
@@ -1,14 +1,17 @@
 package lighthouse
 
 import (
+	"context"
 	"fmt"
 	"github.com/alethio/eth2stats-client/beacon/polling"
+	"github.com/alethio/eth2stats-client/clock"
 	"net/http"
 
 	"github.com/dghubble/sling"
 	"github.com/sirupsen/logrus"
 
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/httpclient"
 	"github.com/alethio/eth2stats-client/types"
 )
 
@@ -17,47 +20,101 @@ var log = logrus.WithField("module", "lighthouse")
 type LighthouseHTTPClient struct {
 	api    *sling.Sling
 	client *http.Client
+	clock  *clock.Clock
 }
 
-func (s *LighthouseHTTPClient) GetVersion() (string, error) {
+func (s *LighthouseHTTPClient) GetVersion(ctx context.Context) (string, error) {
 	path := fmt.Sprintf("node/version")
 	version := new(string)
-	_, err := s.api.New().Get(path).ReceiveSuccess(version)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), version)
 	if err != nil {
 		return "", err
 	}
 	return *version, nil
 }
 
-func (s *LighthouseHTTPClient) GetGenesisTime() (int64, error) {
+func (s *LighthouseHTTPClient) GetGenesisTime(ctx context.Context) (int64, error) {
 	path := fmt.Sprintf("beacon/genesis_time")
 	genesis := new(int64)
-	_, err := s.api.New().Get(path).ReceiveSuccess(genesis)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), genesis)
 	if err != nil {
 		return 0, err
 	}
 	return *genesis, nil
 }
 
-func (s *LighthouseHTTPClient) GetPeerCount() (int64, error) {
+func (s *LighthouseHTTPClient) GetSpec(ctx context.Context) (*types.Spec, error) {
+	path := fmt.Sprintf("spec")
+	type chainSpec struct {
+		MillisecondsPerSlot uint64 `json:"milliseconds_per_slot"`
+	}
+	spec := new(chainSpec)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), spec)
+	if err != nil {
+		return nil, err
+	}
+	// slots per epoch is part of the preset, not the chain spec
+	path = fmt.Sprintf("spec/slots_per_epoch")
+	slotsPerEpoch := new(uint64)
+	_, err = httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), slotsPerEpoch)
+	if err != nil {
+		return nil, err
+	}
+	if spec.MillisecondsPerSlot < 1000 || *slotsPerEpoch == 0 {
+		return nil, beacon.NotImplemented
+	}
+	return &types.Spec{
+		SecondsPerSlot: spec.MillisecondsPerSlot / 1000,
+		SlotsPerEpoch:  *slotsPerEpoch,
+	}, nil
+}
+
+func (s *LighthouseHTTPClient) GetPeerCount(ctx context.Context) (int64, error) {
 	path := fmt.Sprintf("network/peers")
 	peers := new([]string)
-	_, err := s.api.New().Get(path).ReceiveSuccess(peers)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), peers)
 	if err != nil {
 		return 0, err
 	}
 	return int64(len(*peers)), nil
 }
 
-func (s *LighthouseHTTPClient) GetAttestationsInPoolCount() (int64, error) {
+func (s *LighthouseHTTPClient) GetPeers(ctx context.Context) ([]types.Peer, error) {
+	path := fmt.Sprintf("network/peers")
+	ids := new([]string)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), ids)
+	if err != nil {
+		return nil, err
+	}
+	// only the ids of the connected peers are listed
+	peers := make([]types.Peer, 0, len(*ids))
+	for _, id := range *ids {
+		peers = append(peers, types.Peer{ID: id, State: beacon.PeerConnected})
+	}
+	return peers, nil
+}
+
+func (s *LighthouseHTTPClient) GetAttestationsInPoolCount(ctx context.Context) (int64, error) {
+	return 0, beacon.NotImplemented
+}
+
+func (s *LighthouseHTTPClient) GetRecentAttestationsInPoolCount(ctx context.Context) (int64, error) {
 	return 0, beacon.NotImplemented
 }
 
-func (s *LighthouseHTTPClient) GetSyncStatus() (bool, error) {
+func (s *LighthouseHTTPClient) GetSyncStatus(ctx context.Context) (bool, error) {
 	return false, beacon.NotImplemented
 }
 
-func (s *LighthouseHTTPClient) GetChainHead() (*types.ChainHead, error) {
+func (s *LighthouseHTTPClient) GetValidators(ctx context.Context, ids []string) ([]types.Validator, error) {
+	return nil, beacon.NotImplemented
+}
+
+func (s *LighthouseHTTPClient) GetValidatorPerformance(ctx context.Context, epoch uint64, indices []uint64) ([]types.ValidatorPerformance, error) {
+	return nil, beacon.NotImplemented
+}
+
+func (s *LighthouseHTTPClient) GetChainHead(ctx context.Context) (*types.ChainHead, error) {
 	path := fmt.Sprintf("beacon/head")
 	type chainHead struct {
 		HeadSlot           uint64 `json:"slot"`
@@ -69,25 +126,44 @@ func (s *LighthouseHTTPClient) GetChainHead() (*types.ChainHead, error) {
 	}
 
 	head := new(chainHead)
-	_, err := s.api.New().Get(path).ReceiveSuccess(head)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), head)
 	if err != nil {
 		return nil, err
 	}
 	// TODO this returns roots with 0x while prysm doesn't ... which one is the correct form?
-	typesChainHead := types.ChainHead(*head)
+	typesChainHead := types.ChainHead{
+		HeadSlot:           head.HeadSlot,
+		HeadBlockRoot:      head.HeadBlockRoot,
+		FinalizedSlot:      head.FinalizedSlot,
+		FinalizedBlockRoot: head.FinalizedBlockRoot,
+		JustifiedSlot:      head.JustifiedSlot,
+		JustifiedBlockRoot: head.JustifiedBlockRoot,
+	}
 	return &typesChainHead, nil
 }
 
-func (c *LighthouseHTTPClient) SubscribeChainHeads() (beacon.ChainHeadSubscription, error) {
-	sub := polling.NewChainHeadClientPoller(c)
+func (c *LighthouseHTTPClient) SubscribeChainHeads(ctx context.Context) (beacon.ChainHeadSubscription, error) {
+	sub := polling.NewChainHeadClientPoller(ctx, c, c.clock)
 	go sub.Start()
 
 	return sub, nil
 }
 
-func New(httpClient *http.Client, baseURL string) *LighthouseHTTPClient {
+func (c *LighthouseHTTPClient) Capabilities(ctx context.Context) (*beacon.Capabilities, error) {
+	_, err := c.GetSpec(ctx)
+	spec, err := beacon.Probe(err)
+	if err != nil {
+		return nil, err
+	}
+	return &beacon.Capabilities{
+		Spec: spec,
+	}, nil
+}
+
+func New(httpClient *http.Client, baseURL string, chainClock *clock.Clock) *LighthouseHTTPClient {
 	return &LighthouseHTTPClient{
 		api:    sling.New().Client(httpClient).Base(baseURL),
 		client: httpClient,
+		clock:  chainClock,
 	}
 }
@@ -1,13 +1,16 @@
 package nimbus
 
 import (
+	"context"
 	"fmt"
 	"github.com/alethio/eth2stats-client/beacon/polling"
+	"github.com/alethio/eth2stats-client/clock"
 	"github.com/dghubble/sling"
 	"github.com/sirupsen/logrus"
 	"net/http"
 
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/httpclient"
 	"github.com/alethio/eth2stats-client/types"
 )
 
@@ -16,6 +19,7 @@ var log = logrus.WithField("module", "nimbus")
 type NimbusJsonHttp struct {
 	api    *sling.Sling
 	client *http.Client
+	clock  *clock.Clock
 }
 
 type JsonReq struct {
@@ -25,14 +29,14 @@ type JsonReq struct {
 	Params []interface{} `json:"params"`
 }
 
-func (s *NimbusJsonHttp) JsonReq(dest interface{}, method string, params ...interface{}) error {
+func (s *NimbusJsonHttp) JsonReq(ctx context.Context, dest interface{}, method string, params ...interface{}) error {
 	paramsBase := make([]interface{}, 0)
 	paramsBase = append(paramsBase, params...)
-	_, err := s.api.New().Get("").Add("Content-Type", "application/json").BodyJSON(&JsonReq{
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get("").Add("Content-Type", "application/json").BodyJSON(&JsonReq{
 		Method: method,
 		Id:     123,
 		Params: paramsBase,
-	}).ReceiveSuccess(dest)
+	}), dest)
 	return err
 }
 
@@ -41,9 +45,9 @@ type VersionResp struct {
 	Error  interface{} `json:"error"`
 }
 
-func (s *NimbusJsonHttp) GetVersion() (string, error) {
+func (s *NimbusJsonHttp) GetVersion(ctx context.Context) (string, error) {
 	var resp VersionResp
-	err := s.JsonReq(&resp, "getNodeVersion")
+	err := s.JsonReq(ctx, &resp, "getNodeVersion")
 	if err != nil {
 		return "", err
 	}
@@ -53,9 +57,47 @@ func (s *NimbusJsonHttp) GetVersion() (string, error) {
 	return resp.Result, nil
 }
 
-func (s *NimbusJsonHttp) GetGenesisTime() (int64, error) {
-	// TODO: harcoded goerli genesis time. Nimbus has no genesis time API
-	return 1587981600, nil
+type GenesisResp struct {
+	Result struct {
+		GenesisTime uint64 `json:"genesis_time"`
+	} `json:"result"`
+	Error interface{} `json:"error"`
+}
+
+// GoerliGenesisTime is assumed for older nimbus versions, which have no genesis time API.
+const GoerliGenesisTime = 1587981600
+
+func (s *NimbusJsonHttp) GetGenesisTime(ctx context.Context) (int64, error) {
+	var resp GenesisResp
+	err := s.JsonReq(ctx, &resp, "get_v1_beacon_genesis")
+	if err == nil && resp.Error != nil {
+		err = fmt.Errorf("json err: %v", resp.Error)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, err
+		}
+		log.Warnf("getting genesis time: %s; assuming the goerli genesis time", err)
+		return GoerliGenesisTime, nil
+	}
+	return int64(resp.Result.GenesisTime), nil
+}
+
+type SpecResp struct {
+	Result map[string]string `json:"result"`
+	Error  interface{}       `json:"error"`
+}
+
+func (s *NimbusJsonHttp) GetSpec(ctx context.Context) (*types.Spec, error) {
+	var resp SpecResp
+	err := s.JsonReq(ctx, &resp, "get_v1_config_spec")
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("json err: %v", resp.Error)
+	}
+	return beacon.SpecFromConfig(resp.Result, "SECONDS_PER_SLOT", "SLOTS_PER_EPOCH", "CONFIG_NAME")
 }
 
 type NetworkPeersResp struct {
@@ -63,9 +105,9 @@ type NetworkPeersResp struct {
 	Error  interface{} `json:"error"`
 }
 
-func (s *NimbusJsonHttp) GetPeerCount() (int64, error) {
+func (s *NimbusJsonHttp) GetPeerCount(ctx context.Context) (int64, error) {
 	var resp NetworkPeersResp
-	err := s.JsonReq(&resp, "getNetworkPeers")
+	err := s.JsonReq(ctx, &resp, "getNetworkPeers")
 	if err != nil {
 		return 0, err
 	}
@@ -75,7 +117,28 @@ func (s *NimbusJsonHttp) GetPeerCount() (int64, error) {
 	return int64(len(resp.Result)), nil
 }
 
-func (s *NimbusJsonHttp) GetAttestationsInPoolCount() (int64, error) {
+func (s *NimbusJsonHttp) GetPeers(ctx context.Context) ([]types.Peer, error) {
+	var resp NetworkPeersResp
+	err := s.JsonReq(ctx, &resp, "getNetworkPeers")
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("json err: %v", resp.Error)
+	}
+	// only the ids of the connected peers are listed
+	peers := make([]types.Peer, 0, len(resp.Result))
+	for _, id := range resp.Result {
+		peers = append(peers, types.Peer{ID: id, State: beacon.PeerConnected})
+	}
+	return peers, nil
+}
+
+func (s *NimbusJsonHttp) GetAttestationsInPoolCount(ctx context.Context) (int64, error) {
+	return 0, beacon.NotImplemented
+}
+
+func (s *NimbusJsonHttp) GetRecentAttestationsInPoolCount(ctx context.Context) (int64, error) {
 	return 0, beacon.NotImplemented
 }
 
@@ -84,9 +147,9 @@ type SyncingResp struct {
 	Error  interface{} `json:"error"`
 }
 
-func (s *NimbusJsonHttp) GetSyncStatus() (bool, error) {
+func (s *NimbusJsonHttp) GetSyncStatus(ctx context.Context) (bool, error) {
 	var resp SyncingResp
-	err := s.JsonReq(&resp, "getSyncing")
+	err := s.JsonReq(ctx, &resp, "getSyncing")
 	if err != nil {
 		return false, err
 	}
@@ -110,9 +173,17 @@ type ChainHeadResp struct {
 	Error  interface{}     `json:"error"`
 }
 
-func (s *NimbusJsonHttp) GetChainHead() (*types.ChainHead, error) {
+func (s *NimbusJsonHttp) GetValidators(ctx context.Context, ids []string) ([]types.Validator, error) {
+	return nil, beacon.NotImplemented
+}
+
+func (s *NimbusJsonHttp) GetValidatorPerformance(ctx context.Context, epoch uint64, indices []uint64) ([]types.ValidatorPerformance, error) {
+	return nil, beacon.NotImplemented
+}
+
+func (s *NimbusJsonHttp) GetChainHead(ctx context.Context) (*types.ChainHead, error) {
 	var resp ChainHeadResp
-	err := s.JsonReq(&resp, "getChainHead")
+	err := s.JsonReq(ctx, &resp, "getChainHead")
 	if err != nil {
 		return nil, err
 	}
@@ -120,20 +191,37 @@ func (s *NimbusJsonHttp) GetChainHead() (*types.ChainHead, error) {
 		return nil, fmt.Errorf("json err: %v", resp.Error)
 	}
 	// no 0x in roots for nimbus, but that's ok
-	typesChainHead := types.ChainHead(resp.Result)
+	typesChainHead := types.ChainHead{
+		HeadSlot:           resp.Result.HeadSlot,
+		HeadBlockRoot:      resp.Result.HeadBlockRoot,
+		FinalizedSlot:      resp.Result.FinalizedSlot,
+		FinalizedBlockRoot: resp.Result.FinalizedBlockRoot,
+		JustifiedSlot:      resp.Result.JustifiedSlot,
+		JustifiedBlockRoot: resp.Result.JustifiedBlockRoot,
+	}
 	return &typesChainHead, nil
 }
 
-func (c *NimbusJsonHttp) SubscribeChainHeads() (beacon.ChainHeadSubscription, error) {
-	sub := polling.NewChainHeadClientPoller(c)
+func (c *NimbusJsonHttp) SubscribeChainHeads(ctx context.Context) (beacon.ChainHeadSubscription, error) {
+	sub := polling.NewChainHeadClientPoller(ctx, c, c.clock)
 	go sub.Start()
 
 	return sub, nil
 }
 
-func New(httpClient *http.Client, baseURL string) *NimbusJsonHttp {
+func (c *NimbusJsonHttp) Capabilities(ctx context.Context) (*beacon.Capabilities, error) {
+	// older versions don't know the standard config method, which is an error like any other
+	_, err := c.GetSpec(ctx)
+	return &beacon.Capabilities{
+		SyncStatus: true,
+		Spec:       err == nil,
+	}, nil
+}
+
+func New(httpClient *http.Client, baseURL string, chainClock *clock.Clock) *NimbusJsonHttp {
 	return &NimbusJsonHttp{
 		api:    sling.New().Client(httpClient).Base(baseURL),
 		client: httpClient,
+		clock:  chainClock,
 	}
 }
@@ -0,0 +1,63 @@
+package beacon
+
+import (
+	"strings"
+
+	"github.com/alethio/eth2stats-client/types"
+)
+
+const (
+	PeerConnected = "connected"
+	PeerInbound   = "inbound"
+	PeerOutbound  = "outbound"
+
+	// ClientUnknown is the client of peers without an agent; ClientOther the one of agents that are not recognized.
+	ClientUnknown = "unknown"
+	ClientOther   = "other"
+)
+
+// Clients are the beacon node implementations recognized in peer agents.
+var Clients = []string{"lighthouse", "prysm", "teku", "nimbus", "lodestar", "grandine"}
+
+// ClientOf tells the client of a peer from its agent.
+func ClientOf(agent string) string {
+	if agent == "" {
+		return ClientUnknown
+	}
+	agent = strings.ToLower(agent)
+	for _, client := range Clients {
+		if strings.Contains(agent, client) {
+			return client
+		}
+	}
+	return ClientOther
+}
+
+// SummarizePeers counts the peers by direction, state and client. Peers without a state are taken to be connected,
+// as nodes that don't tell only list connected peers.
+func SummarizePeers(peers []types.Peer) types.PeerStats {
+	stats := types.PeerStats{
+		States:  make(map[string]int64),
+		Clients: make(map[string]int64),
+	}
+	for _, p := range peers {
+		state := p.State
+		if state == "" {
+			state = PeerConnected
+		}
+		stats.States[state]++
+		if state != PeerConnected {
+			continue
+		}
+
+		stats.Connected++
+		switch p.Direction {
+		case PeerInbound:
+			stats.Inbound++
+		case PeerOutbound:
+			stats.Outbound++
+		}
+		stats.Clients[ClientOf(p.Agent)]++
+	}
+	return stats
+}
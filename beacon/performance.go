@@ -0,0 +1,95 @@
+package beacon
+
+import (
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// AttesterDuty is the committee a validator attests in, at a slot.
+type AttesterDuty struct {
+	ValidatorIndex uint64
+	Slot           uint64
+	CommitteeIndex uint64
+	// Position of the validator in its committee, which is its bit in the aggregation bits.
+	Position uint64
+}
+
+type ProposerDuty struct {
+	ValidatorIndex uint64
+	Slot           uint64
+}
+
+// Block holds what's needed from a block to check the duties of validators.
+type Block struct {
+	Slot         uint64
+	Attestations []Attestation
+}
+
+type Attestation struct {
+	Slot           uint64
+	CommitteeIndex uint64
+	// AggregationBits is the SSZ bitlist of the committee members that attested.
+	AggregationBits []byte
+}
+
+// EvaluatePerformance tells how validators did in an epoch, given their duties in that epoch and the
+// blocks that could include their attestations: those of the epoch and the next one. Only validators
+// with an attester duty, which every active validator has, are evaluated.
+func EvaluatePerformance(epoch uint64, attesterDuties []AttesterDuty, proposerDuties []ProposerDuty, blocks []Block) []types.ValidatorPerformance {
+	proposed := make(map[uint64]bool, len(blocks))
+	for _, block := range blocks {
+		proposed[block.Slot] = true
+	}
+
+	performance := make([]types.ValidatorPerformance, 0, len(attesterDuties))
+	for _, duty := range attesterDuties {
+		p := types.ValidatorPerformance{
+			Index: duty.ValidatorIndex,
+			Epoch: epoch,
+		}
+		for _, block := range blocks {
+			if block.Slot <= duty.Slot {
+				continue
+			}
+			if !block.Includes(duty) {
+				continue
+			}
+			delay := block.Slot - duty.Slot
+			if !p.AttestationIncluded || delay < p.InclusionDelay {
+				p.InclusionDelay = delay
+			}
+			p.AttestationIncluded = true
+		}
+		for _, proposal := range proposerDuties {
+			if proposal.ValidatorIndex != duty.ValidatorIndex {
+				continue
+			}
+			p.ProposalsScheduled++
+			if !proposed[proposal.Slot] {
+				p.ProposalsMissed++
+			}
+		}
+		performance = append(performance, p)
+	}
+	return performance
+}
+
+// Includes tells whether the block includes the attestation of the given duty.
+func (b Block) Includes(duty AttesterDuty) bool {
+	if b.Slot <= duty.Slot {
+		return false
+	}
+	for _, attestation := range b.Attestations {
+		if attestation.Slot == duty.Slot && attestation.CommitteeIndex == duty.CommitteeIndex && bitSet(attestation.AggregationBits, duty.Position) {
+			return true
+		}
+	}
+	return false
+}
+
+// bitSet reads a bit of an SSZ bitlist, where bit i is in byte i/8, least significant bit first.
+func bitSet(bits []byte, i uint64) bool {
+	if i/8 >= uint64(len(bits)) {
+		return false
+	}
+	return bits[i/8]&(1<<(i%8)) != 0
+}
@@ -1,7 +1,10 @@
 package polling
 
 import (
+	"context"
+
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/clock"
 	"github.com/sirupsen/logrus"
 
 	"time"
@@ -10,6 +13,7 @@ import (
 )
 
 const (
+	// PollingInterval is used unless the clock is configured with a poll interval.
 	PollingInterval = time.Second
 )
 
@@ -18,51 +22,85 @@ var log = logrus.WithField("module", "polling")
 type ChainHeadClientPoller struct {
 	data   chan types.ChainHead
 	client beacon.Client
+	clock  *clock.Clock
 
-	stopChan chan bool
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // Check interface
 var _ = beacon.ChainHeadSubscription((*ChainHeadClientPoller)(nil))
 
-func NewChainHeadClientPoller(client beacon.Client) *ChainHeadClientPoller {
+// NewChainHeadClientPoller polls the client for new heads. The clock may be nil,
+// or not know the genesis time yet, in which case polls happen every poll interval.
+func NewChainHeadClientPoller(ctx context.Context, client beacon.Client, chainClock *clock.Clock) *ChainHeadClientPoller {
+	ctx, cancel := context.WithCancel(ctx)
 	return &ChainHeadClientPoller{
 		data:   make(chan types.ChainHead),
 		client: client,
+		clock:  chainClock,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
 func (s *ChainHeadClientPoller) Start() {
 	log.Info("polling for new heads")
+	defer close(s.data)
 	var lastHead *types.ChainHead
 
 	for {
 		select {
-		case <-s.stopChan:
-			close(s.data)
+		case <-s.ctx.Done():
 			return
 		default:
-			head, err := s.client.GetChainHead()
+			ctx, cancel := context.WithTimeout(s.ctx, beacon.CallTimeout)
+			head, err := s.client.GetChainHead(ctx)
+			cancel()
 			if err != nil {
 				log.Errorf("failed to poll for chain head")
-				time.Sleep(PollingInterval)
+				s.sleep(nil)
 				continue
 			}
 			if lastHead == nil || *lastHead != *head {
-				s.data <- types.ChainHead{
+				select {
+				case s.data <- types.ChainHead{
 					HeadSlot:           head.HeadSlot,
 					HeadBlockRoot:      head.HeadBlockRoot,
 					FinalizedSlot:      head.FinalizedSlot,
 					FinalizedBlockRoot: head.FinalizedBlockRoot,
 					JustifiedSlot:      head.JustifiedSlot,
 					JustifiedBlockRoot: head.JustifiedBlockRoot,
+					ParentRoot:         head.ParentRoot,
+				}:
+				case <-s.ctx.Done():
+					return
 				}
 				lastHead = head
 			}
 
-			time.Sleep(PollingInterval)
+			s.sleep(head)
+		}
+	}
+}
+
+// sleep waits for the next poll. Once the head of the current slot has been seen,
+// nothing new is expected until the next slot, so polls are spaced out accordingly.
+func (s *ChainHeadClientPoller) sleep(head *types.ChainHead) {
+	now := time.Now()
+	interval := s.clock.PollInterval()
+	if interval == 0 {
+		interval = PollingInterval
+	}
+	next := now.Add(interval)
+	if tick, ok := s.clock.NextSlotTick(now); ok {
+		slot, started := s.clock.SlotAt(now)
+		caughtUp := !started || (head != nil && head.HeadSlot >= slot)
+		if caughtUp || tick.Before(next) {
+			next = tick
 		}
 	}
+	clock.SleepUntil(s.ctx, next)
 }
 
 func (s *ChainHeadClientPoller) Channel() <-chan types.ChainHead {
@@ -70,5 +108,5 @@ func (s *ChainHeadClientPoller) Channel() <-chan types.ChainHead {
 }
 
 func (s *ChainHeadClientPoller) Close() {
-	s.stopChan <- true
+	s.cancel()
 }
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/golang/protobuf/ptypes/empty"
 	prysmAPI "github.com/prysmaticlabs/ethereumapis/eth/v1alpha1"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 
 	"github.com/alethio/eth2stats-client/beacon"
 	"github.com/alethio/eth2stats-client/types"
@@ -30,14 +33,14 @@ type PrysmGRPCClient struct {
 	node   prysmAPI.NodeClient
 }
 
-func New(config Config) *PrysmGRPCClient {
+func New(config Config) (*PrysmGRPCClient, error) {
 	log.Info("setting up beacon client connection")
 
 	var dialOpt grpc.DialOption
 	if config.TLSCert != "" {
 		creds, err := credentials.NewClientTLSFromFile(config.TLSCert, "")
 		if err != nil {
-			log.Fatalf("failed to create tls credentials: %v", err)
+			return nil, fmt.Errorf("failed to create tls credentials: %v", err)
 		}
 		dialOpt = grpc.WithTransportCredentials(creds)
 	} else {
@@ -51,7 +54,7 @@ func New(config Config) *PrysmGRPCClient {
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(ClientMaxReceiveMessageSize)),
 	)
 	if err != nil {
-		log.Fatalf("failed to connect to prysm: %v", err)
+		return nil, fmt.Errorf("failed to connect to prysm: %v", err)
 	}
 
 	beaconAPI := prysmAPI.NewBeaconChainClient(conn)
@@ -61,11 +64,11 @@ func New(config Config) *PrysmGRPCClient {
 		config: config,
 		beacon: beaconAPI,
 		node:   nodeAPI,
-	}
+	}, nil
 }
 
-func (c *PrysmGRPCClient) GetVersion() (string, error) {
-	version, err := c.node.GetVersion(context.Background(), &empty.Empty{})
+func (c *PrysmGRPCClient) GetVersion(ctx context.Context) (string, error) {
+	version, err := c.node.GetVersion(ctx, &empty.Empty{})
 	if err != nil {
 		return "", fmt.Errorf("prysm: getting version: %s", err)
 	}
@@ -73,8 +76,8 @@ func (c *PrysmGRPCClient) GetVersion() (string, error) {
 	return version.GetVersion(), nil
 }
 
-func (c *PrysmGRPCClient) GetGenesisTime() (int64, error) {
-	genesis, err := c.node.GetGenesis(context.Background(), &empty.Empty{})
+func (c *PrysmGRPCClient) GetGenesisTime(ctx context.Context) (int64, error) {
+	genesis, err := c.node.GetGenesis(ctx, &empty.Empty{})
 	if err != nil {
 		return 0, fmt.Errorf("prysm: getting genesis time: %s", err)
 	}
@@ -82,8 +85,21 @@ func (c *PrysmGRPCClient) GetGenesisTime() (int64, error) {
 	return genesis.GetGenesisTime().GetSeconds(), nil
 }
 
-func (c *PrysmGRPCClient) GetPeerCount() (int64, error) {
-	peers, err := c.node.ListPeers(context.Background(), &empty.Empty{})
+func (c *PrysmGRPCClient) GetSpec(ctx context.Context) (*types.Spec, error) {
+	config, err := c.beacon.GetBeaconConfig(ctx, &empty.Empty{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil, beacon.NotImplemented
+	}
+	if err != nil {
+		return nil, fmt.Errorf("prysm: getting beacon config: %s", err)
+	}
+
+	// prysm names the values after the fields of its config struct
+	return beacon.SpecFromConfig(config.GetConfig(), "SecondsPerSlot", "SlotsPerEpoch", "ConfigName")
+}
+
+func (c *PrysmGRPCClient) GetPeerCount(ctx context.Context) (int64, error) {
+	peers, err := c.node.ListPeers(ctx, &empty.Empty{})
 	if err != nil {
 		log.Error(err)
 		return 0, err
@@ -92,11 +108,41 @@ func (c *PrysmGRPCClient) GetPeerCount() (int64, error) {
 	return int64(len(peers.Peers)), nil
 }
 
-func (c *PrysmGRPCClient) GetAttestationsInPoolCount() (int64, error) {
+func (c *PrysmGRPCClient) GetPeers(ctx context.Context) ([]types.Peer, error) {
+	resp, err := c.node.ListPeers(ctx, &empty.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("prysm: listing peers: %s", err)
+	}
+
+	// only connected peers are listed, by their multiaddress
+	peers := make([]types.Peer, 0, len(resp.Peers))
+	for _, p := range resp.Peers {
+		peer := types.Peer{
+			Address: p.Address,
+			State:   beacon.PeerConnected,
+		}
+		if i := strings.LastIndex(p.Address, "/p2p/"); i >= 0 {
+			peer.ID = p.Address[i+len("/p2p/"):]
+		}
+		switch p.Direction {
+		case prysmAPI.PeerDirection_INBOUND:
+			peer.Direction = beacon.PeerInbound
+		case prysmAPI.PeerDirection_OUTBOUND:
+			peer.Direction = beacon.PeerOutbound
+		}
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
+func (c *PrysmGRPCClient) GetAttestationsInPoolCount(ctx context.Context) (int64, error) {
 	req := &prysmAPI.AttestationPoolRequest{
 		PageSize: 1,
 	}
-	resp, err := c.beacon.AttestationPool(context.Background(), req)
+	resp, err := c.beacon.AttestationPool(ctx, req)
+	if status.Code(err) == codes.Unimplemented {
+		return 0, beacon.NotImplemented
+	}
 	if err != nil {
 		log.Error(err)
 		return 0, err
@@ -111,8 +157,13 @@ func (c *PrysmGRPCClient) GetAttestationsInPoolCount() (int64, error) {
 	return int64(resp.TotalSize), nil
 }
 
-func (c *PrysmGRPCClient) GetSyncStatus() (bool, error) {
-	sync, err := c.node.GetSyncStatus(context.Background(), &empty.Empty{})
+func (c *PrysmGRPCClient) GetRecentAttestationsInPoolCount(ctx context.Context) (int64, error) {
+	// the whole pool is counted instead
+	return 0, beacon.NotImplemented
+}
+
+func (c *PrysmGRPCClient) GetSyncStatus(ctx context.Context) (bool, error) {
+	sync, err := c.node.GetSyncStatus(ctx, &empty.Empty{})
 	if err != nil {
 		log.Error(err)
 		return false, err
@@ -121,8 +172,8 @@ func (c *PrysmGRPCClient) GetSyncStatus() (bool, error) {
 	return sync.GetSyncing(), nil
 }
 
-func (c *PrysmGRPCClient) GetChainHead() (*types.ChainHead, error) {
-	head, err := c.beacon.GetChainHead(context.Background(), &empty.Empty{})
+func (c *PrysmGRPCClient) GetChainHead(ctx context.Context) (*types.ChainHead, error) {
+	head, err := c.beacon.GetChainHead(ctx, &empty.Empty{})
 	if err != nil {
 		return nil, fmt.Errorf("prysm: getting chain head: %s", err)
 	}
@@ -137,16 +188,38 @@ func (c *PrysmGRPCClient) GetChainHead() (*types.ChainHead, error) {
 	}, nil
 }
 
-func (c *PrysmGRPCClient) SubscribeChainHeads() (beacon.ChainHeadSubscription, error) {
-	stream, err := c.beacon.StreamChainHead(context.Background(), &empty.Empty{})
+func (c *PrysmGRPCClient) SubscribeChainHeads(ctx context.Context) (beacon.ChainHeadSubscription, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.beacon.StreamChainHead(ctx, &empty.Empty{})
 	if err != nil {
+		cancel()
 		log.Error(err)
 
 		return nil, err
 	}
 
-	sub := NewChainHeadSubscription()
+	sub := NewChainHeadSubscription(cancel)
 	go sub.FeedFromStream(stream)
 
 	return sub, nil
 }
+
+func (c *PrysmGRPCClient) Capabilities(ctx context.Context) (*beacon.Capabilities, error) {
+	// older nodes don't serve the attestation pool or their config
+	_, err := c.GetAttestationsInPoolCount(ctx)
+	attestations, err := beacon.Probe(err)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.GetSpec(ctx)
+	spec, err := beacon.Probe(err)
+	if err != nil {
+		return nil, err
+	}
+	return &beacon.Capabilities{
+		AttestationsInPool: attestations,
+		SyncStatus:         true,
+		Spec:               spec,
+		Validators:         true,
+	}, nil
+}
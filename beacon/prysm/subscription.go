@@ -1,6 +1,7 @@
 package prysm
 
 import (
+	"context"
 	"encoding/hex"
 
 	prysmAPI "github.com/prysmaticlabs/ethereumapis/eth/v1alpha1"
@@ -11,40 +12,42 @@ import (
 type ChainHeadSubscription struct {
 	data chan types.ChainHead
 
-	stopChan chan bool
+	// cancels the context of the stream
+	cancel context.CancelFunc
 }
 
-func NewChainHeadSubscription() *ChainHeadSubscription {
+func NewChainHeadSubscription(cancel context.CancelFunc) *ChainHeadSubscription {
 	return &ChainHeadSubscription{
-		data: make(chan types.ChainHead),
+		data:   make(chan types.ChainHead),
+		cancel: cancel,
 	}
 }
 
 func (s *ChainHeadSubscription) FeedFromStream(stream prysmAPI.BeaconChain_StreamChainHeadClient) {
 	log.Info("listening on stream")
 
+	defer close(s.data)
+
 	for {
+		// Recv fails once the stream context is cancelled
+		data, err := stream.Recv()
+		if err != nil {
+			return
+		}
+
+		log.WithField("headSlot", data.GetHeadSlot()).Debug("got chain head")
+
 		select {
-		case <-s.stopChan:
-			close(s.data)
+		case s.data <- types.ChainHead{
+			HeadSlot:           data.HeadSlot,
+			HeadBlockRoot:      hex.EncodeToString(data.HeadBlockRoot),
+			FinalizedSlot:      data.FinalizedSlot,
+			FinalizedBlockRoot: hex.EncodeToString(data.FinalizedBlockRoot),
+			JustifiedSlot:      data.JustifiedSlot,
+			JustifiedBlockRoot: hex.EncodeToString(data.JustifiedBlockRoot),
+		}:
+		case <-stream.Context().Done():
 			return
-		default:
-			data, err := stream.Recv()
-			if err != nil {
-				close(s.data)
-				return
-			}
-
-			log.WithField("headSlot", data.GetHeadSlot()).Debug("got chain head")
-
-			s.data <- types.ChainHead{
-				HeadSlot:           data.HeadSlot,
-				HeadBlockRoot:      hex.EncodeToString(data.HeadBlockRoot),
-				FinalizedSlot:      data.FinalizedSlot,
-				FinalizedBlockRoot: hex.EncodeToString(data.FinalizedBlockRoot),
-				JustifiedSlot:      data.JustifiedSlot,
-				JustifiedBlockRoot: hex.EncodeToString(data.JustifiedBlockRoot),
-			}
 		}
 	}
 }
@@ -54,5 +57,5 @@ func (s *ChainHeadSubscription) Channel() <-chan types.ChainHead {
 }
 
 func (s *ChainHeadSubscription) Close() {
-	s.stopChan <- true
+	s.cancel()
 }
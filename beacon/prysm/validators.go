@@ -0,0 +1,176 @@
+package prysm
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	prysmAPI "github.com/prysmaticlabs/ethereumapis/eth/v1alpha1"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+const farFutureEpoch = math.MaxUint64
+
+func (c *PrysmGRPCClient) GetValidators(ctx context.Context, ids []string) ([]types.Validator, error) {
+	req := &prysmAPI.ListValidatorBalancesRequest{}
+	for _, id := range ids {
+		if strings.HasPrefix(id, "0x") {
+			pubkey, err := hex.DecodeString(strings.TrimPrefix(id, "0x"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid validator public key %s: %s", id, err)
+			}
+			req.PublicKeys = append(req.PublicKeys, pubkey)
+			continue
+		}
+		index, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validator index %s: %s", id, err)
+		}
+		req.Indices = append(req.Indices, index)
+	}
+	balances, err := c.beacon.ListValidatorBalances(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("prysm: listing validator balances: %s", err)
+	}
+	head, err := c.beacon.GetChainHead(ctx, &empty.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("prysm: getting chain head: %s", err)
+	}
+
+	validators := make([]types.Validator, 0, len(balances.Balances))
+	for _, b := range balances.Balances {
+		v, err := c.beacon.GetValidator(ctx, &prysmAPI.GetValidatorRequest{
+			QueryFilter: &prysmAPI.GetValidatorRequest_Index{Index: b.Index},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("prysm: getting validator %d: %s", b.Index, err)
+		}
+		validators = append(validators, types.Validator{
+			Index:   b.Index,
+			Pubkey:  "0x" + hex.EncodeToString(b.PublicKey),
+			Status:  validatorStatus(v, head.HeadEpoch),
+			Balance: b.Balance,
+		})
+	}
+	return validators, nil
+}
+
+// validatorStatus names the status of a validator like the standard API does.
+func validatorStatus(v *prysmAPI.Validator, epoch uint64) string {
+	switch {
+	case v.ActivationEpoch > epoch:
+		if v.ActivationEligibilityEpoch == farFutureEpoch {
+			return "pending_initialized"
+		}
+		return "pending_queued"
+	case v.ExitEpoch > epoch:
+		if v.Slashed {
+			return "active_slashed"
+		}
+		if v.ExitEpoch != farFutureEpoch {
+			return "active_exiting"
+		}
+		return "active_ongoing"
+	case v.WithdrawableEpoch > epoch:
+		if v.Slashed {
+			return "exited_slashed"
+		}
+		return "exited_unslashed"
+	default:
+		return "withdrawal_possible"
+	}
+}
+
+// GetValidatorPerformance checks the duties of the validators against the blocks of the epoch and the next one.
+// The performance API of prysm only tells balances, so it can't be used for this.
+func (c *PrysmGRPCClient) GetValidatorPerformance(ctx context.Context, epoch uint64, indices []uint64) ([]types.ValidatorPerformance, error) {
+	// assignments only tell the public key of the validator they are for
+	balances, err := c.beacon.ListValidatorBalances(ctx, &prysmAPI.ListValidatorBalancesRequest{Indices: indices})
+	if err != nil {
+		return nil, fmt.Errorf("prysm: listing validator balances: %s", err)
+	}
+	indexOf := make(map[string]uint64, len(balances.Balances))
+	for _, b := range balances.Balances {
+		indexOf[string(b.PublicKey)] = b.Index
+	}
+
+	var attesterDuties []beacon.AttesterDuty
+	var proposerDuties []beacon.ProposerDuty
+	req := &prysmAPI.ListValidatorAssignmentsRequest{
+		QueryFilter: &prysmAPI.ListValidatorAssignmentsRequest_Epoch{Epoch: epoch},
+		Indices:     indices,
+	}
+	for {
+		assignments, err := c.beacon.ListValidatorAssignments(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("prysm: listing validator assignments: %s", err)
+		}
+		for _, a := range assignments.Assignments {
+			index, ok := indexOf[string(a.PublicKey)]
+			if !ok {
+				continue
+			}
+			for position, member := range a.BeaconCommittees {
+				if member == index {
+					attesterDuties = append(attesterDuties, beacon.AttesterDuty{
+						ValidatorIndex: index,
+						Slot:           a.AttesterSlot,
+						CommitteeIndex: a.CommitteeIndex,
+						Position:       uint64(position),
+					})
+				}
+			}
+			if a.ProposerSlot != 0 {
+				proposerDuties = append(proposerDuties, beacon.ProposerDuty{
+					ValidatorIndex: index,
+					Slot:           a.ProposerSlot,
+				})
+			}
+		}
+		if assignments.NextPageToken == "" {
+			break
+		}
+		req.PageToken = assignments.NextPageToken
+	}
+
+	// attestations can be included until the end of the next epoch
+	var blocks []beacon.Block
+	for _, e := range []uint64{epoch, epoch + 1} {
+		req := &prysmAPI.ListBlocksRequest{
+			QueryFilter: &prysmAPI.ListBlocksRequest_Epoch{Epoch: e},
+		}
+		for {
+			resp, err := c.beacon.ListBlocks(ctx, req)
+			if err != nil {
+				return nil, fmt.Errorf("prysm: listing blocks: %s", err)
+			}
+			for _, container := range resp.BlockContainers {
+				block := container.GetBlock().GetBlock()
+				if block == nil {
+					continue
+				}
+				b := beacon.Block{Slot: block.Slot}
+				for _, a := range block.GetBody().GetAttestations() {
+					b.Attestations = append(b.Attestations, beacon.Attestation{
+						Slot:            a.GetData().GetSlot(),
+						CommitteeIndex:  a.GetData().GetCommitteeIndex(),
+						AggregationBits: a.AggregationBits,
+					})
+				}
+				blocks = append(blocks, b)
+			}
+			if resp.NextPageToken == "" {
+				break
+			}
+			req.PageToken = resp.NextPageToken
+		}
+	}
+
+	return beacon.EvaluatePerformance(epoch, attesterDuties, proposerDuties, blocks), nil
+}
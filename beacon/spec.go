@@ -0,0 +1,43 @@
+package beacon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// SpecFromConfig reads the spec from a node's config served as string values,
+// under the given names for the seconds per slot, slots per epoch and config name.
+func SpecFromConfig(config map[string]string, secondsPerSlot, slotsPerEpoch, configName string) (*types.Spec, error) {
+	spec := &types.Spec{
+		ConfigName: config[configName],
+	}
+	for name, dest := range map[string]*uint64{
+		secondsPerSlot: &spec.SecondsPerSlot,
+		slotsPerEpoch:  &spec.SlotsPerEpoch,
+	} {
+		value, ok := config[name]
+		if !ok {
+			return nil, fmt.Errorf("spec is missing %s", name)
+		}
+		v, err := parseSpecUint(value)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s from spec: %s", name, err)
+		}
+		if v == 0 {
+			return nil, fmt.Errorf("spec has zero %s", name)
+		}
+		*dest = v
+	}
+	return spec, nil
+}
+
+// parseSpecUint reads a decimal value, or a hexadecimal one with a 0x prefix; leading zeros don't make it octal.
+func parseSpecUint(value string) (uint64, error) {
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		return strconv.ParseUint(value[2:], 16, 64)
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
@@ -1,16 +1,20 @@
 package teku
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/alethio/eth2stats-client/beacon/polling"
+	"github.com/alethio/eth2stats-client/clock"
 
 	"github.com/dghubble/sling"
 	"github.com/sirupsen/logrus"
 
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/httpclient"
+	"github.com/alethio/eth2stats-client/beacon/v1"
 	"github.com/alethio/eth2stats-client/types"
 )
 
@@ -19,23 +23,24 @@ var log = logrus.WithField("module", "teku")
 type TekuHTTPClient struct {
 	api    *sling.Sling
 	client *http.Client
+	clock  *clock.Clock
 }
 
-func (s *TekuHTTPClient) GetVersion() (string, error) {
+func (s *TekuHTTPClient) GetVersion(ctx context.Context) (string, error) {
 	path := fmt.Sprintf("node/version")
 	version := new(string)
-	_, err := s.api.New().Get(path).ReceiveSuccess(version)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), version)
 	if err != nil {
 		return "", err
 	}
 	return *version, nil
 }
 
-func (s *TekuHTTPClient) GetGenesisTime() (int64, error) {
+func (s *TekuHTTPClient) GetGenesisTime(ctx context.Context) (int64, error) {
 	// node/genesis_time instead of beacon/genesis_time like lighthouse.
 	path := fmt.Sprintf("node/genesis_time")
 	genesis := new(string)
-	_, err := s.api.New().Get(path).ReceiveSuccess(genesis)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), genesis)
 	if err != nil {
 		return 0, err
 	}
@@ -47,36 +52,75 @@ func (s *TekuHTTPClient) GetGenesisTime() (int64, error) {
 	return genesisTime, nil
 }
 
-func (s *TekuHTTPClient) GetPeerCount() (int64, error) {
+func (s *TekuHTTPClient) GetSpec(ctx context.Context) (*types.Spec, error) {
+	// Not served by this API; newer Teku versions serve it through the standard (v1) API.
+	return nil, beacon.NotImplemented
+}
+
+func (s *TekuHTTPClient) GetPeerCount(ctx context.Context) (int64, error) {
 	// Teku also has a `network/peers` endpoint like lighthouse, but this is more efficient.
 	path := fmt.Sprintf("network/peer_count")
 	peerCount := new(int64)
-	_, err := s.api.New().Get(path).ReceiveSuccess(peerCount)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), peerCount)
 	if err != nil {
 		return 0, err
 	}
 	return *peerCount, nil
 }
 
-func (s *TekuHTTPClient) GetAttestationsInPoolCount() (int64, error) {
+func (s *TekuHTTPClient) GetPeers(ctx context.Context) ([]types.Peer, error) {
+	path := fmt.Sprintf("network/peer_ids")
+	ids := new([]string)
+	resp, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), ids)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, beacon.NotImplemented
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status code %d", resp.StatusCode)
+	}
+	// only the ids of the connected peers are listed
+	peers := make([]types.Peer, 0, len(*ids))
+	for _, id := range *ids {
+		peers = append(peers, types.Peer{ID: id, State: beacon.PeerConnected})
+	}
+	return peers, nil
+}
+
+func (s *TekuHTTPClient) GetAttestationsInPoolCount(ctx context.Context) (int64, error) {
 	return 0, beacon.NotImplemented
 }
 
-func (s *TekuHTTPClient) GetSyncStatus() (bool, error) {
+func (s *TekuHTTPClient) GetRecentAttestationsInPoolCount(ctx context.Context) (int64, error) {
+	// counted through the standard (v1) pool endpoint, which newer Teku versions serve next to this API
+	return v1.CountPoolAttestations(ctx, s.client, s.api, s.clock)
+}
+
+func (s *TekuHTTPClient) GetSyncStatus(ctx context.Context) (bool, error) {
 	path := fmt.Sprintf("node/syncing")
 	type syncStatus struct {
 		Syncing bool `json:"syncing"`
 		// Note: ignore "sync_status" field
 	}
 	status := new(syncStatus)
-	_, err := s.api.New().Get(path).ReceiveSuccess(status)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), status)
 	if err != nil {
 		return false, err
 	}
 	return status.Syncing, nil
 }
 
-func (s *TekuHTTPClient) GetChainHead() (*types.ChainHead, error) {
+func (s *TekuHTTPClient) GetValidators(ctx context.Context, ids []string) ([]types.Validator, error) {
+	return nil, beacon.NotImplemented
+}
+
+func (s *TekuHTTPClient) GetValidatorPerformance(ctx context.Context, epoch uint64, indices []uint64) ([]types.ValidatorPerformance, error) {
+	return nil, beacon.NotImplemented
+}
+
+func (s *TekuHTTPClient) GetChainHead(ctx context.Context) (*types.ChainHead, error) {
 	path := fmt.Sprintf("beacon/chainhead")
 	type chainHead struct {
 		// Slight difference from lighthouse, to be standardized in new API proposal.
@@ -89,7 +133,7 @@ func (s *TekuHTTPClient) GetChainHead() (*types.ChainHead, error) {
 		// Note: some fields, like epochs and previous justified epoch, are ignored.
 	}
 	head := new(chainHead)
-	_, err := s.api.New().Get(path).ReceiveSuccess(head)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), head)
 	if err != nil {
 		return nil, err
 	}
@@ -125,16 +169,25 @@ func (s *TekuHTTPClient) GetChainHead() (*types.ChainHead, error) {
 	return &typesChainHead, nil
 }
 
-func (c *TekuHTTPClient) SubscribeChainHeads() (beacon.ChainHeadSubscription, error) {
-	sub := polling.NewChainHeadClientPoller(c)
+func (c *TekuHTTPClient) SubscribeChainHeads(ctx context.Context) (beacon.ChainHeadSubscription, error) {
+	sub := polling.NewChainHeadClientPoller(ctx, c, c.clock)
 	go sub.Start()
 
 	return sub, nil
 }
 
-func New(httpClient *http.Client, baseURL string) *TekuHTTPClient {
+func (c *TekuHTTPClient) Capabilities(ctx context.Context) (*beacon.Capabilities, error) {
+	return &beacon.Capabilities{
+		// older versions don't serve the standard API
+		RecentAttestationsInPool: v1.ProbePoolAttestations(ctx, c.client, c.api, c.clock),
+		SyncStatus:               true,
+	}, nil
+}
+
+func New(httpClient *http.Client, baseURL string, chainClock *clock.Clock) *TekuHTTPClient {
 	return &TekuHTTPClient{
 		api:    sling.New().Client(httpClient).Base(baseURL),
 		client: httpClient,
+		clock:  chainClock,
 	}
 }
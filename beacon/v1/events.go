@@ -0,0 +1,289 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+const (
+	EventsPath               = "eth/v1/events?topics=head,finalized_checkpoint"
+	EventStreamRetryInterval = 5 * time.Second
+	// MinEventStreamRetryInterval bounds the retry interval a node may ask for, to not reconnect in a tight loop.
+	MinEventStreamRetryInterval = time.Second
+)
+
+// Error returned when the node does not serve the standard event stream.
+var ErrEventStreamUnavailable = errors.New("event stream is not available")
+
+// ChainHeadEventSubscription follows the chain head through the server-sent events
+// of the standard API, reconnecting (with Last-Event-ID) whenever the stream drops.
+type ChainHeadEventSubscription struct {
+	client *V1HTTPClient
+	stream *http.Client
+	data   chan types.ChainHead
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lastEventID   string
+	retryInterval time.Duration
+	head          *types.ChainHead
+}
+
+// Check interface
+var _ = beacon.ChainHeadSubscription((*ChainHeadEventSubscription)(nil))
+
+func NewChainHeadEventSubscription(ctx context.Context, client *V1HTTPClient) *ChainHeadEventSubscription {
+	// The stream is long-lived, so it can't share the request timeout of the regular calls.
+	streamClient := *client.client
+	streamClient.Timeout = 0
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &ChainHeadEventSubscription{
+		client:        client,
+		stream:        &streamClient,
+		data:          make(chan types.ChainHead),
+		ctx:           ctx,
+		cancel:        cancel,
+		retryInterval: EventStreamRetryInterval,
+	}
+}
+
+func (s *ChainHeadEventSubscription) connect() (*http.Response, error) {
+	base, err := url.Parse(s.client.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(EventsPath)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", base.ResolveReference(ref).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(s.ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+
+	resp, err := s.stream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		resp.Body.Close()
+		return nil, ErrEventStreamUnavailable
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("event stream responded with status code %d", resp.StatusCode)
+	}
+}
+
+// Start consumes the already opened stream and keeps it open until the subscription is closed.
+func (s *ChainHeadEventSubscription) Start(resp *http.Response) {
+	log.Info("listening on event stream")
+	defer close(s.data)
+
+	for {
+		// heads may have been missed while (re)connecting
+		s.resync()
+
+		err := s.consume(resp)
+		if s.ctx.Err() != nil {
+			return
+		}
+		log.Warnf("event stream interrupted: %s", err)
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(s.retryInterval):
+			}
+
+			resp, err = s.connect()
+			if err == nil {
+				break
+			}
+			if s.ctx.Err() != nil {
+				return
+			}
+			log.Errorf("reconnecting to event stream: %s", err)
+		}
+		log.Info("reconnected to event stream")
+	}
+}
+
+func (s *ChainHeadEventSubscription) resync() {
+	ctx, cancel := context.WithTimeout(s.ctx, beacon.CallTimeout)
+	defer cancel()
+	head, err := s.client.GetChainHead(ctx)
+	if err != nil {
+		log.Errorf("failed to get chain head: %s", err)
+		return
+	}
+	s.emit(*head)
+}
+
+func (s *ChainHeadEventSubscription) consume(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	var event string
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return errors.New("stream closed by node")
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// an empty line dispatches the event
+		if line == "" {
+			if data.Len() > 0 {
+				s.handleEvent(event, data.String())
+			}
+			event = ""
+			data.Reset()
+			continue
+		}
+		// comments are used as keep-alives
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field = line[:i]
+			value = strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "id":
+			s.lastEventID = value
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 64); err == nil {
+				s.retryInterval = time.Duration(ms) * time.Millisecond
+				if s.retryInterval < MinEventStreamRetryInterval {
+					s.retryInterval = MinEventStreamRetryInterval
+				}
+			}
+		}
+	}
+}
+
+func (s *ChainHeadEventSubscription) handleEvent(event string, data string) {
+	ctx, cancel := context.WithTimeout(s.ctx, beacon.CallTimeout)
+	defer cancel()
+
+	if s.head == nil {
+		// without a full head to start from there is nothing to update
+		s.resync()
+		return
+	}
+	head := *s.head
+
+	switch event {
+	case "head":
+		type headEvent struct {
+			Slot            JsonUint64 `json:"slot"`
+			Block           string     `json:"block"`
+			EpochTransition bool       `json:"epoch_transition"`
+		}
+		ev := new(headEvent)
+		if err := json.Unmarshal([]byte(data), ev); err != nil {
+			log.Errorf("failed to decode head event: %s", err)
+			return
+		}
+		log.WithField("headSlot", uint64(ev.Slot)).Debug("got head event")
+
+		head.HeadSlot = uint64(ev.Slot)
+		head.HeadBlockRoot = ev.Block
+		// the event does not carry the parent, which reorg detection relies on
+		head.ParentRoot = ""
+		if header, err := s.client.getBlockHeader(ctx, ev.Block); err != nil {
+			log.Warnf("failed to get parent of head: %s", err)
+		} else {
+			head.ParentRoot = header.parentRoot
+		}
+		if ev.EpochTransition {
+			if err := s.client.updateFinalityCheckpoints(ctx, &head); err != nil {
+				log.Warnf("failed to update finality checkpoints: %s", err)
+			}
+		}
+	case "finalized_checkpoint":
+		type finalizedCheckpointEvent struct {
+			Block string     `json:"block"`
+			Epoch JsonUint64 `json:"epoch"`
+		}
+		ev := new(finalizedCheckpointEvent)
+		if err := json.Unmarshal([]byte(data), ev); err != nil {
+			log.Errorf("failed to decode finalized checkpoint event: %s", err)
+			return
+		}
+		log.WithField("epoch", uint64(ev.Epoch)).Debug("got finalized checkpoint event")
+
+		finalizedSlot, err := s.client.startSlotOfEpoch(uint64(ev.Epoch))
+		if err != nil {
+			log.Errorf("failed to handle finalized checkpoint event: %s", err)
+			return
+		}
+		head.FinalizedBlockRoot = ev.Block
+		head.FinalizedSlot = finalizedSlot
+		// the event does not carry the justified checkpoint
+		if err := s.client.updateFinalityCheckpoints(ctx, &head); err != nil {
+			log.Warnf("failed to update finality checkpoints: %s", err)
+		}
+	default:
+		return
+	}
+
+	s.emit(head)
+}
+
+func (s *ChainHeadEventSubscription) emit(head types.ChainHead) {
+	if s.head != nil && *s.head == head {
+		return
+	}
+	s.head = &head
+
+	select {
+	case s.data <- head:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *ChainHeadEventSubscription) Channel() <-chan types.ChainHead {
+	return s.data
+}
+
+func (s *ChainHeadEventSubscription) Close() {
+	s.cancel()
+}
@@ -1,15 +1,20 @@
 package v1
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/httpclient"
 	"github.com/alethio/eth2stats-client/beacon/polling"
+	"github.com/alethio/eth2stats-client/clock"
 	"github.com/alethio/eth2stats-client/types"
 	"github.com/dghubble/sling"
 	"github.com/sirupsen/logrus"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 var log = logrus.WithField("module", "v1")
@@ -32,11 +37,16 @@ func (v *JsonUint64) UnmarshalJSON(b []byte) error {
 }
 
 type V1HTTPClient struct {
-	api    *sling.Sling
-	client *http.Client
+	api     *sling.Sling
+	client  *http.Client
+	baseURL string
+	clock   *clock.Clock
+
+	// noPeerAgents is set once the node turned out not to tell the agents of its peers
+	noPeerAgents int32
 }
 
-func (s *V1HTTPClient) GetVersion() (string, error) {
+func (s *V1HTTPClient) GetVersion(ctx context.Context) (string, error) {
 	path := "eth/v1/node/version"
 	type versionResponse struct {
 		Data struct {
@@ -44,14 +54,14 @@ func (s *V1HTTPClient) GetVersion() (string, error) {
 		} `json:"data,omitempty"`
 	}
 	response := new(versionResponse)
-	_, err := s.api.New().Get(path).ReceiveSuccess(response)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
 	if err != nil {
 		return "", err
 	}
 	return response.Data.Version, nil
 }
 
-func (s *V1HTTPClient) GetGenesisTime() (int64, error) {
+func (s *V1HTTPClient) GetGenesisTime(ctx context.Context) (int64, error) {
 	path := "eth/v1/beacon/genesis"
 	type genesisResponse struct {
 		Data struct {
@@ -59,41 +69,147 @@ func (s *V1HTTPClient) GetGenesisTime() (int64, error) {
 		} `json:"data,omitempty"`
 	}
 	response := new(genesisResponse)
-	_, err := s.api.New().Get(path).ReceiveSuccess(response)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
 	if err != nil {
 		return 0, err
 	}
 	return int64(response.Data.GenesisTime), nil
 }
 
-func (s *V1HTTPClient) GetPeerCount() (int64, error) {
+func (s *V1HTTPClient) GetSpec(ctx context.Context) (*types.Spec, error) {
+	path := "eth/v1/config/spec"
+	type specResponse struct {
+		Data map[string]string `json:"data,omitempty"`
+	}
+	response := new(specResponse)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data) == 0 {
+		return nil, beacon.NotImplemented
+	}
+	return beacon.SpecFromConfig(response.Data, "SECONDS_PER_SLOT", "SLOTS_PER_EPOCH", "CONFIG_NAME")
+}
+
+func (s *V1HTTPClient) GetPeerCount(ctx context.Context) (int64, error) {
+	peers, err := s.GetPeers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return beacon.SummarizePeers(peers).Connected, nil
+}
+
+func (s *V1HTTPClient) GetPeers(ctx context.Context) ([]types.Peer, error) {
 	path := "eth/v1/node/peers"
 	type peersResponse struct {
 		Data []struct {
-			State string `json:"state,omitempty"`
+			PeerID    string `json:"peer_id,omitempty"`
+			State     string `json:"state,omitempty"`
+			Direction string `json:"direction,omitempty"`
+			Address   string `json:"last_seen_p2p_address,omitempty"`
 		} `json:"data,omitempty"`
 	}
 	response := new(peersResponse)
-	_, err := s.api.New().Get(path).ReceiveSuccess(response)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	connected := int64(0)
+	// the standard API does not tell the agents of peers
+	agents := s.getPeerAgents(ctx)
+	peers := make([]types.Peer, 0, len(response.Data))
 	for _, p := range response.Data {
-		if p.State == "connected" {
-			connected++
-		}
+		peers = append(peers, types.Peer{
+			ID:        p.PeerID,
+			Direction: p.Direction,
+			State:     p.State,
+			Address:   p.Address,
+			Agent:     agents[p.PeerID],
+		})
 	}
-	return connected, nil
+	return peers, nil
 }
 
-func (s *V1HTTPClient) GetAttestationsInPoolCount() (int64, error) {
-	// TODO: There's an attestations pool endpoint, but it lists way too much.
-	//       So much, that querying it a lot is similar to a self-induced DoS attack.
+// getPeerAgents returns the agents of the peers by their id, from the peer details lighthouse serves
+// next to the standard API. Other nodes are only asked once; agents are left out if they can't be had.
+func (s *V1HTTPClient) getPeerAgents(ctx context.Context) map[string]string {
+	if atomic.LoadInt32(&s.noPeerAgents) != 0 {
+		return nil
+	}
+	path := "lighthouse/peers"
+	type peerDetails []struct {
+		PeerID   string `json:"peer_id"`
+		PeerInfo struct {
+			Client struct {
+				AgentString string `json:"agent_string"`
+			} `json:"client"`
+		} `json:"peer_info"`
+	}
+	response := new(peerDetails)
+	resp, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
+	if err != nil {
+		log.Warnf("getting peer agents: %s", err)
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Debugf("node does not tell the agents of its peers (status code %d)", resp.StatusCode)
+		atomic.StoreInt32(&s.noPeerAgents, 1)
+		return nil
+	}
+	agents := make(map[string]string, len(*response))
+	for _, p := range *response {
+		agents[p.PeerID] = p.PeerInfo.Client.AgentString
+	}
+	return agents
+}
+
+func (s *V1HTTPClient) GetAttestationsInPoolCount(ctx context.Context) (int64, error) {
+	// the whole pool lists way too much to be fetched on every poll
 	return 0, beacon.NotImplemented
 }
 
-func (s *V1HTTPClient) GetSyncStatus() (bool, error) {
+func (s *V1HTTPClient) GetRecentAttestationsInPoolCount(ctx context.Context) (int64, error) {
+	return CountPoolAttestations(ctx, s.client, s.api, s.clock)
+}
+
+// CountPoolAttestations counts the attestations in the pool of a node serving the standard API for the
+// last beacon.AttestationPoolSlots slots. The whole pool lists way too much to be fetched on every poll,
+// so only those slots are asked for, and they are counted as they stream in rather than decoded.
+func CountPoolAttestations(ctx context.Context, client *http.Client, api *sling.Sling, chainClock *clock.Clock) (int64, error) {
+	current, ok := chainClock.CurrentSlot()
+	if !ok {
+		// nothing to attest to before genesis
+		return 0, nil
+	}
+	var total int64
+	for i := uint64(0); i < beacon.AttestationPoolSlots && i <= current; i++ {
+		path := fmt.Sprintf("eth/v1/beacon/pool/attestations?slot=%d", current-i)
+		count, err := httpclient.CountArray(ctx, client, api.New().Get(path), "data", beacon.MaxAttestationPoolBytes)
+		if statusErr, ok := err.(*httpclient.StatusError); ok {
+			switch statusErr.StatusCode {
+			case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+				return 0, beacon.NotImplemented
+			}
+		}
+		if err != nil {
+			return 0, fmt.Errorf("counting pool attestations of slot %d: %s", current-i, err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// ProbePoolAttestations tells whether the node serves its attestation pool. Only a missing endpoint means
+// it doesn't; a node that fails to list its pool right now is still polled for it.
+func ProbePoolAttestations(ctx context.Context, client *http.Client, api *sling.Sling, chainClock *clock.Clock) bool {
+	_, err := CountPoolAttestations(ctx, client, api, chainClock)
+	if err != nil && err != beacon.NotImplemented {
+		log.Warnf("probing attestation pool: %s", err)
+	}
+	return err != beacon.NotImplemented
+}
+
+func (s *V1HTTPClient) GetSyncStatus(ctx context.Context) (bool, error) {
 	path := "eth/v1/node/syncing"
 	type syncingResponse struct {
 		Data struct {
@@ -101,14 +217,14 @@ func (s *V1HTTPClient) GetSyncStatus() (bool, error) {
 		} `json:"data,omitempty"`
 	}
 	response := new(syncingResponse)
-	_, err := s.api.New().Get(path).ReceiveSuccess(response)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
 	if err != nil {
 		return false, err
 	}
 	return response.Data.SyncDistance != 0, nil
 }
 
-func (s *V1HTTPClient) GetChainHead() (*types.ChainHead, error) {
+func (s *V1HTTPClient) GetChainHead(ctx context.Context) (*types.ChainHead, error) {
 
 	typesChainHead := new(types.ChainHead)
 
@@ -119,18 +235,28 @@ func (s *V1HTTPClient) GetChainHead() (*types.ChainHead, error) {
 		} `json:"data,omitempty"`
 	}
 	headRootResponse := new(headRootType)
-	_, err := s.api.New().Get(headRootPath).ReceiveSuccess(headRootResponse)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(headRootPath), headRootResponse)
 	if err != nil {
 		return nil, err
 	}
 	typesChainHead.HeadBlockRoot = headRootResponse.Data.HeadBlockRoot
 
-	slot, err := s.getBlockSlot(typesChainHead.HeadBlockRoot)
+	header, err := s.getBlockHeader(ctx, typesChainHead.HeadBlockRoot)
 	if err != nil {
 		return nil, err
 	}
-	typesChainHead.HeadSlot = slot
+	typesChainHead.HeadSlot = header.slot
+	typesChainHead.ParentRoot = header.parentRoot
 
+	err = s.updateFinalityCheckpoints(ctx, typesChainHead)
+	if err != nil {
+		return nil, err
+	}
+	return typesChainHead, nil
+}
+
+// updateFinalityCheckpoints fills in the finalized and justified checkpoints of the given head.
+func (s *V1HTTPClient) updateFinalityCheckpoints(ctx context.Context, typesChainHead *types.ChainHead) error {
 	finalityCheckpointsPath := "eth/v1/beacon/states/head/finality_checkpoints"
 	type finalityCheckpointsType struct {
 		Data struct {
@@ -145,50 +271,100 @@ func (s *V1HTTPClient) GetChainHead() (*types.ChainHead, error) {
 		} `json:"data,omitempty"`
 	}
 	finalityCheckpointsResponse := new(finalityCheckpointsType)
-	_, err = s.api.New().Get(finalityCheckpointsPath).ReceiveSuccess(finalityCheckpointsResponse)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(finalityCheckpointsPath), finalityCheckpointsResponse)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	justifiedSlot, err := s.startSlotOfEpoch(uint64(finalityCheckpointsResponse.Data.Justified.Epoch))
+	if err != nil {
+		return err
+	}
+	finalizedSlot, err := s.startSlotOfEpoch(uint64(finalityCheckpointsResponse.Data.Finalized.Epoch))
+	if err != nil {
+		return err
 	}
 	typesChainHead.JustifiedBlockRoot = finalityCheckpointsResponse.Data.Justified.Root
-	typesChainHead.JustifiedSlot, _ = s.startSlotOfEpoch(uint64(finalityCheckpointsResponse.Data.Justified.Epoch))
+	typesChainHead.JustifiedSlot = justifiedSlot
 	typesChainHead.FinalizedBlockRoot = finalityCheckpointsResponse.Data.Finalized.Root
-	typesChainHead.FinalizedSlot, _ = s.startSlotOfEpoch(uint64(finalityCheckpointsResponse.Data.Finalized.Epoch))
-	return typesChainHead, nil
+	typesChainHead.FinalizedSlot = finalizedSlot
+	return nil
 }
 
-func (s *V1HTTPClient) SubscribeChainHeads() (beacon.ChainHeadSubscription, error) {
-	sub := polling.NewChainHeadClientPoller(s)
-	go sub.Start()
+func (s *V1HTTPClient) SubscribeChainHeads(ctx context.Context) (beacon.ChainHeadSubscription, error) {
+	sub := NewChainHeadEventSubscription(ctx, s)
+	resp, err := sub.connect()
+	if err == ErrEventStreamUnavailable {
+		sub.Close()
+		log.Warn("node does not support the event stream; falling back to polling for new heads")
+		poller := polling.NewChainHeadClientPoller(ctx, s, s.clock)
+		go poller.Start()
+
+		return poller, nil
+	}
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	go sub.Start(resp)
 
 	return sub, nil
 }
 
-func New(httpClient *http.Client, baseURL string) *V1HTTPClient {
+func (s *V1HTTPClient) Capabilities(ctx context.Context) (*beacon.Capabilities, error) {
+	_, err := s.GetSpec(ctx)
+	spec, err := beacon.Probe(err)
+	if err != nil {
+		return nil, err
+	}
+	return &beacon.Capabilities{
+		RecentAttestationsInPool: ProbePoolAttestations(ctx, s.client, s.api, s.clock),
+		SyncStatus:               true,
+		Spec:                     spec,
+		Validators:               true,
+	}, nil
+}
+
+func New(httpClient *http.Client, baseURL string, chainClock *clock.Clock) *V1HTTPClient {
 	return &V1HTTPClient{
-		api:    sling.New().Client(httpClient).Base(baseURL),
-		client: httpClient,
+		api:     sling.New().Client(httpClient).Base(baseURL),
+		client:  httpClient,
+		baseURL: baseURL,
+		clock:   chainClock,
 	}
 }
 
-func (s *V1HTTPClient) getBlockSlot(blockId string) (uint64, error) {
+type blockHeader struct {
+	slot       uint64
+	parentRoot string
+}
+
+func (s *V1HTTPClient) getBlockHeader(ctx context.Context, blockId string) (*blockHeader, error) {
 	blockHeaderPath := fmt.Sprintf("eth/v1/beacon/headers/%s", blockId)
 	type blockHeaderTypeResponse struct {
 		Data struct {
 			Header struct {
 				Message struct {
-					Slot JsonUint64 `json:"slot,omitempty"`
+					Slot       JsonUint64 `json:"slot,omitempty"`
+					ParentRoot string     `json:"parent_root,omitempty"`
 				} `json:"message,omitempty"`
 			} `json:"header,omitempty"`
 		} `json:"data,omitempty"`
 	}
 	blockHeaderResponse := new(blockHeaderTypeResponse)
-	_, err := s.api.New().Get(blockHeaderPath).ReceiveSuccess(blockHeaderResponse)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(blockHeaderPath), blockHeaderResponse)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return uint64(blockHeaderResponse.Data.Header.Message.Slot), nil
+	return &blockHeader{
+		slot:       uint64(blockHeaderResponse.Data.Header.Message.Slot),
+		parentRoot: blockHeaderResponse.Data.Header.Message.ParentRoot,
+	}, nil
 }
 
+// startSlotOfEpoch relies on the clock for the slots per epoch, which is set from the spec of the node.
 func (s *V1HTTPClient) startSlotOfEpoch(epoch uint64) (uint64, error) {
-	return epoch * 32, nil
+	if s.clock == nil || s.clock.SlotsPerEpoch() == 0 {
+		return 0, errors.New("slots per epoch unknown")
+	}
+	return s.clock.StartSlotOfEpoch(epoch), nil
 }
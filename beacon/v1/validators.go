@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/httpclient"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+func (s *V1HTTPClient) GetValidators(ctx context.Context, ids []string) ([]types.Validator, error) {
+	path := "eth/v1/beacon/states/head/validators"
+	type validatorsQuery struct {
+		ID []string `url:"id"`
+	}
+	type validatorsResponse struct {
+		Data []struct {
+			Index     JsonUint64 `json:"index"`
+			Balance   JsonUint64 `json:"balance"`
+			Status    string     `json:"status"`
+			Validator struct {
+				Pubkey string `json:"pubkey"`
+			} `json:"validator"`
+		} `json:"data"`
+	}
+	response := new(validatorsResponse)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path).QueryStruct(&validatorsQuery{ID: ids}), response)
+	if err != nil {
+		return nil, err
+	}
+
+	validators := make([]types.Validator, 0, len(response.Data))
+	for _, v := range response.Data {
+		validators = append(validators, types.Validator{
+			Index:   uint64(v.Index),
+			Pubkey:  v.Validator.Pubkey,
+			Status:  v.Status,
+			Balance: uint64(v.Balance),
+		})
+	}
+	return validators, nil
+}
+
+func (s *V1HTTPClient) GetValidatorPerformance(ctx context.Context, epoch uint64, indices []uint64) ([]types.ValidatorPerformance, error) {
+	if s.clock == nil || s.clock.SlotsPerEpoch() == 0 {
+		return nil, errors.New("slots per epoch unknown")
+	}
+
+	attesterDuties, err := s.getAttesterDuties(ctx, epoch, indices)
+	if err != nil {
+		return nil, fmt.Errorf("getting attester duties: %s", err)
+	}
+	// nodes may only serve the proposer duties of the current and next epoch
+	proposerDuties, err := s.getProposerDuties(ctx, epoch, indices)
+	if err != nil {
+		log.Warnf("getting proposer duties of epoch %d: %s; leaving out proposals", epoch, err)
+	}
+
+	blocks, err := s.getDutyBlocks(ctx, epoch, attesterDuties, proposerDuties)
+	if err != nil {
+		return nil, err
+	}
+	return beacon.EvaluatePerformance(epoch, attesterDuties, proposerDuties, blocks), nil
+}
+
+// getDutyBlocks returns the blocks needed to check the given duties, in slot order. Attestations can be
+// included until the end of the next epoch, but usually are right away, so blocks are only fetched until
+// every attestation was found included and every proposal slot was passed.
+func (s *V1HTTPClient) getDutyBlocks(ctx context.Context, epoch uint64, attesterDuties []beacon.AttesterDuty, proposerDuties []beacon.ProposerDuty) ([]beacon.Block, error) {
+	if len(attesterDuties) == 0 {
+		return nil, nil
+	}
+	first := attesterDuties[0].Slot + 1
+	var lastProposal uint64
+	pending := make(map[beacon.AttesterDuty]bool, len(attesterDuties))
+	for _, duty := range attesterDuties {
+		if duty.Slot+1 < first {
+			first = duty.Slot + 1
+		}
+		pending[duty] = true
+	}
+	for _, proposal := range proposerDuties {
+		if proposal.Slot < first {
+			first = proposal.Slot
+		}
+		if proposal.Slot > lastProposal {
+			lastProposal = proposal.Slot
+		}
+	}
+
+	var blocks []beacon.Block
+	last := s.clock.StartSlotOfEpoch(epoch+2) - 1
+	for slot := first; slot <= last; slot++ {
+		if len(pending) == 0 && slot > lastProposal {
+			break
+		}
+		block, err := s.getBlock(ctx, slot)
+		if err != nil {
+			return nil, fmt.Errorf("getting block at slot %d: %s", slot, err)
+		}
+		if block == nil {
+			continue
+		}
+		blocks = append(blocks, *block)
+		for duty := range pending {
+			if block.Includes(duty) {
+				delete(pending, duty)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+func (s *V1HTTPClient) getAttesterDuties(ctx context.Context, epoch uint64, indices []uint64) ([]beacon.AttesterDuty, error) {
+	path := fmt.Sprintf("eth/v1/validator/duties/attester/%d", epoch)
+	type attesterDutiesResponse struct {
+		Data []struct {
+			ValidatorIndex          JsonUint64 `json:"validator_index"`
+			CommitteeIndex          JsonUint64 `json:"committee_index"`
+			ValidatorCommitteeIndex JsonUint64 `json:"validator_committee_index"`
+			Slot                    JsonUint64 `json:"slot"`
+		} `json:"data"`
+	}
+	body := make([]string, 0, len(indices))
+	for _, index := range indices {
+		body = append(body, strconv.FormatUint(index, 10))
+	}
+	response := new(attesterDutiesResponse)
+	_, err := httpclient.ReceiveSuccess(ctx, s.api.New().Post(path).BodyJSON(body), response)
+	if err != nil {
+		return nil, err
+	}
+
+	duties := make([]beacon.AttesterDuty, 0, len(response.Data))
+	for _, d := range response.Data {
+		duties = append(duties, beacon.AttesterDuty{
+			ValidatorIndex: uint64(d.ValidatorIndex),
+			Slot:           uint64(d.Slot),
+			CommitteeIndex: uint64(d.CommitteeIndex),
+			Position:       uint64(d.ValidatorCommitteeIndex),
+		})
+	}
+	return duties, nil
+}
+
+// getProposerDuties returns the proposer duties of the given validators; the node returns those of all validators.
+func (s *V1HTTPClient) getProposerDuties(ctx context.Context, epoch uint64, indices []uint64) ([]beacon.ProposerDuty, error) {
+	path := fmt.Sprintf("eth/v1/validator/duties/proposer/%d", epoch)
+	type proposerDutiesResponse struct {
+		Data []struct {
+			ValidatorIndex JsonUint64 `json:"validator_index"`
+			Slot           JsonUint64 `json:"slot"`
+		} `json:"data"`
+	}
+	response := new(proposerDutiesResponse)
+	resp, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status code %d", resp.StatusCode)
+	}
+
+	wanted := make(map[uint64]bool, len(indices))
+	for _, index := range indices {
+		wanted[index] = true
+	}
+	var duties []beacon.ProposerDuty
+	for _, d := range response.Data {
+		if wanted[uint64(d.ValidatorIndex)] {
+			duties = append(duties, beacon.ProposerDuty{
+				ValidatorIndex: uint64(d.ValidatorIndex),
+				Slot:           uint64(d.Slot),
+			})
+		}
+	}
+	return duties, nil
+}
+
+// getBlock returns the block at the given slot, or nil if the slot is empty.
+func (s *V1HTTPClient) getBlock(ctx context.Context, slot uint64) (*beacon.Block, error) {
+	path := fmt.Sprintf("eth/v1/beacon/blocks/%d", slot)
+	type blockResponse struct {
+		Data struct {
+			Message struct {
+				Slot JsonUint64 `json:"slot"`
+				Body struct {
+					Attestations []struct {
+						AggregationBits string `json:"aggregation_bits"`
+						Data            struct {
+							Slot  JsonUint64 `json:"slot"`
+							Index JsonUint64 `json:"index"`
+						} `json:"data"`
+					} `json:"attestations"`
+				} `json:"body"`
+			} `json:"message"`
+		} `json:"data"`
+	}
+	response := new(blockResponse)
+	resp, err := httpclient.ReceiveSuccess(ctx, s.api.New().Get(path), response)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status code %d", resp.StatusCode)
+	}
+
+	block := &beacon.Block{
+		Slot: uint64(response.Data.Message.Slot),
+	}
+	for _, a := range response.Data.Message.Body.Attestations {
+		bits, err := hex.DecodeString(strings.TrimPrefix(a.AggregationBits, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("decoding aggregation bits: %s", err)
+		}
+		block.Attestations = append(block.Attestations, beacon.Attestation{
+			Slot:            uint64(a.Data.Slot),
+			CommitteeIndex:  uint64(a.Data.Index),
+			AggregationBits: bits,
+		})
+	}
+	return block, nil
+}
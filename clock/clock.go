@@ -0,0 +1,160 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	SecondsPerSlot uint64
+	SlotsPerEpoch  uint64
+	// Offset into each slot at which ticks happen, to give blocks time to propagate.
+	Offset time.Duration
+	// PollInterval is how often to poll in between ticks, while waiting for data of the current slot.
+	PollInterval time.Duration
+}
+
+// Clock tells slots and epochs apart from the genesis time of the chain.
+// Until the genesis time is known, it has no opinion on when things should happen.
+type Clock struct {
+	mu      sync.RWMutex
+	config  Config
+	genesis time.Time
+}
+
+func New(config Config) *Clock {
+	return &Clock{
+		config: config,
+	}
+}
+
+func (c *Clock) SetGenesis(genesisTime int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.genesis = time.Unix(genesisTime, 0)
+}
+
+// SetSpec replaces the configured slot timing with the one of the chain.
+func (c *Clock) SetSpec(secondsPerSlot, slotsPerEpoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config.SecondsPerSlot = secondsPerSlot
+	c.config.SlotsPerEpoch = slotsPerEpoch
+}
+
+// Genesis returns the genesis time, if known.
+func (c *Clock) Genesis() (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.genesis, !c.genesis.IsZero()
+}
+
+// timing returns the genesis time once the clock can tell slots apart.
+func (c *Clock) timing() (time.Time, bool) {
+	genesis, ok := c.Genesis()
+	return genesis, ok && c.SecondsPerSlot() > 0 && c.SlotsPerEpoch() > 0
+}
+
+func (c *Clock) SecondsPerSlot() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.config.SecondsPerSlot
+}
+
+func (c *Clock) SlotsPerEpoch() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.config.SlotsPerEpoch
+}
+
+// PollInterval returns the configured poll interval; 0 if the clock is nil.
+func (c *Clock) PollInterval() time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.config.PollInterval
+}
+
+func (c *Clock) SlotDuration() time.Duration {
+	return time.Duration(c.SecondsPerSlot()) * time.Second
+}
+
+// SlotAt returns the slot at the given time; false if the time is unknown or before genesis.
+func (c *Clock) SlotAt(t time.Time) (uint64, bool) {
+	genesis, ok := c.timing()
+	if !ok || t.Before(genesis) {
+		return 0, false
+	}
+	return uint64(t.Sub(genesis) / c.SlotDuration()), true
+}
+
+func (c *Clock) CurrentSlot() (uint64, bool) {
+	return c.SlotAt(time.Now())
+}
+
+func (c *Clock) EpochOf(slot uint64) uint64 {
+	return slot / c.SlotsPerEpoch()
+}
+
+func (c *Clock) StartSlotOfEpoch(epoch uint64) uint64 {
+	return epoch * c.SlotsPerEpoch()
+}
+
+func (c *Clock) SlotStart(slot uint64) time.Time {
+	genesis, _ := c.Genesis()
+	return genesis.Add(time.Duration(slot) * c.SlotDuration())
+}
+
+// NextSlotTick returns the first tick after the given time, at the configured offset into a slot.
+// Before genesis, that is the first tick of slot 0.
+func (c *Clock) NextSlotTick(after time.Time) (time.Time, bool) {
+	return c.nextTick(after, 1)
+}
+
+// NextEpochTick is like NextSlotTick, for the first slot of an epoch.
+func (c *Clock) NextEpochTick(after time.Time) (time.Time, bool) {
+	return c.nextTick(after, c.SlotsPerEpoch())
+}
+
+func (c *Clock) nextTick(after time.Time, everySlots uint64) (time.Time, bool) {
+	genesis, ok := c.timing()
+	if !ok {
+		return time.Time{}, false
+	}
+	c.mu.RLock()
+	offset := c.config.Offset
+	c.mu.RUnlock()
+
+	first := genesis.Add(offset)
+	if after.Before(first) {
+		return first, true
+	}
+	period := time.Duration(everySlots) * c.SlotDuration()
+	periods := after.Sub(first)/period + 1
+	return first.Add(periods * period), true
+}
+
+// SleepUntil blocks until the given time; false if the context was cancelled first.
+func SleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,295 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/clock"
+	"github.com/alethio/eth2stats-client/core"
+	"github.com/alethio/eth2stats-client/core/finality"
+	"github.com/alethio/eth2stats-client/core/telemetry"
+	"github.com/alethio/eth2stats-client/types"
+	metricsWatcher "github.com/alethio/eth2stats-client/watcher/metrics"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Call every method of the configured beacon nodes and report the results, without contacting the eth2stats server",
+	Run: func(cmd *cobra.Command, args []string) {
+		nodeName, _ := cmd.Flags().GetString("node")
+		heads, _ := cmd.Flags().GetInt("heads")
+		headsTimeout, _ := cmd.Flags().GetDuration("heads-timeout")
+
+		configs, err := nodeConfigs()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ok := true
+		found := false
+		for _, config := range configs {
+			if nodeName != "" && config.Eth2stats.NodeName != nodeName {
+				continue
+			}
+			found = true
+			for _, addr := range config.BeaconNode.Addrs {
+				ok = checkBeaconNode(cmd.OutOrStdout(), config, addr, heads, headsTimeout) && ok
+			}
+			if config.BeaconNode.MetricsAddr != "" {
+				ok = checkMetricsAddr(cmd.OutOrStdout(), config) && ok
+			}
+		}
+		if !found {
+			log.Fatalf("no node named %q", nodeName)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+// checkTable prints one row per call: its outcome, result and latency.
+type checkTable struct {
+	w      *tabwriter.Writer
+	failed bool
+}
+
+func newCheckTable(w io.Writer, title string) *checkTable {
+	t := &checkTable{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+	fmt.Fprintf(t.w, "%s\n", title)
+	fmt.Fprintf(t.w, "  CALL\tSTATUS\tLATENCY\tRESULT\n")
+	return t
+}
+
+// run calls f with a timeout and adds its row; it returns whether the call succeeded.
+func (t *checkTable) run(call string, timeout time.Duration, f func(ctx context.Context) (string, error)) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := f(ctx)
+	latency := time.Since(start).Round(time.Microsecond)
+
+	status := "ok"
+	switch {
+	case err == beacon.NotImplemented:
+		status, result = "n/a", "not implemented"
+	case err != nil:
+		status, result = "FAIL", err.Error()
+		t.failed = true
+	}
+	fmt.Fprintf(t.w, "  %s\t%s\t%s\t%s\n", call, status, latency, result)
+	return err == nil
+}
+
+func (t *checkTable) skip(call string, reason string) {
+	fmt.Fprintf(t.w, "  %s\t%s\t%s\t%s\n", call, "skipped", "-", reason)
+}
+
+func (t *checkTable) note(row string, result string) {
+	fmt.Fprintf(t.w, "  %s\t%s\t%s\t%s\n", row, "info", "-", result)
+}
+
+func (t *checkTable) flush() bool {
+	t.w.Flush()
+	return !t.failed
+}
+
+// checkBeaconNode calls every beacon.Client method on a single node address and prints the results.
+// It returns false if any call failed; calls the node does not implement are not failures.
+func checkBeaconNode(w io.Writer, config core.Config, addr string, heads int, headsTimeout time.Duration) bool {
+	t := newCheckTable(w, fmt.Sprintf("node %q, beacon node %s (%s)", config.Eth2stats.NodeName, addr, config.BeaconNode.Type))
+	defer fmt.Fprintln(w)
+
+	var client beacon.Client
+	var chainClock *clock.Clock
+	if !t.run("setup", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		var err error
+		client, chainClock, err = core.NewBeaconClient(ctx, config, addr)
+		return fmt.Sprintf("%T", client), err
+	}) {
+		return t.flush()
+	}
+
+	t.run("GetVersion", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		return client.GetVersion(ctx)
+	})
+	t.run("GetGenesisTime", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		genesis, err := client.GetGenesisTime(ctx)
+		if err != nil {
+			return "", err
+		}
+		chainClock.SetGenesis(genesis)
+		return time.Unix(genesis, 0).UTC().Format(time.RFC3339), nil
+	})
+	t.run("GetSpec", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		spec, err := client.GetSpec(ctx)
+		if err != nil {
+			return "", err
+		}
+		chainClock.SetSpec(spec.SecondsPerSlot, spec.SlotsPerEpoch)
+		return fmt.Sprintf("%s, %ds slots, %d slots per epoch", spec.ConfigName, spec.SecondsPerSlot, spec.SlotsPerEpoch), nil
+	})
+	t.run("Capabilities", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		capabilities, err := client.Capabilities(ctx)
+		if err != nil {
+			return "", err
+		}
+		var supported []string
+		for name, ok := range capabilities.Matrix() {
+			if ok {
+				supported = append(supported, name)
+			}
+		}
+		sort.Strings(supported)
+		return strings.Join(supported, ", "), nil
+	})
+	t.run("GetPeerCount", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		peers, err := client.GetPeerCount(ctx)
+		return fmt.Sprint(peers), err
+	})
+	t.run("GetPeers", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		peers, err := client.GetPeers(ctx)
+		if err != nil {
+			return "", err
+		}
+		stats := beacon.SummarizePeers(peers)
+		var clients []string
+		for client, count := range stats.Clients {
+			clients = append(clients, fmt.Sprintf("%s %d", client, count))
+		}
+		sort.Strings(clients)
+		return fmt.Sprintf("%d listed, %d connected (%d inbound, %d outbound), clients: %s",
+			len(peers), stats.Connected, stats.Inbound, stats.Outbound, strings.Join(clients, ", ")), nil
+	})
+	t.run("GetAttestationsInPoolCount", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		attestations, err := client.GetAttestationsInPoolCount(ctx)
+		return fmt.Sprint(attestations), err
+	})
+	t.run("GetRecentAttestationsInPoolCount", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		attestations, err := client.GetRecentAttestationsInPoolCount(ctx)
+		return fmt.Sprintf("%d in the last %d slots", attestations, beacon.AttestationPoolSlots), err
+	})
+	t.run("GetSyncStatus", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		syncing, err := client.GetSyncStatus(ctx)
+		return fmt.Sprintf("syncing: %t", syncing), err
+	})
+	var head *types.ChainHead
+	t.run("GetChainHead", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+		var err error
+		head, err = client.GetChainHead(ctx)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("head %d %s, finalized %d", head.HeadSlot, head.HeadBlockRoot, head.FinalizedSlot), nil
+	})
+	if head != nil {
+		chainConfig := finality.Config{
+			StalledEpochs: config.Chain.FinalityStalledEpochs,
+			LaggingSlots:  config.Chain.HeadLaggingSlots,
+		}
+		if f, ok := finality.Evaluate(*head, chainClock, chainConfig, time.Now()); ok {
+			summary := fmt.Sprintf("%d epochs since finality, head %d slots behind", f.EpochsSinceFinality, f.HeadLagSlots)
+			if f.FinalityStalled {
+				summary += ", finality stalled"
+			}
+			if f.HeadLagging {
+				summary += ", head lagging"
+			}
+			// about the chain rather than the calls, so it does not fail the check
+			t.note("finality", summary)
+		}
+	}
+
+	var indices []uint64
+	if len(config.Validators) == 0 {
+		t.skip("GetValidators", "no validators configured")
+	} else {
+		t.run("GetValidators", beacon.CallTimeout, func(ctx context.Context) (string, error) {
+			validators, err := client.GetValidators(ctx, config.Validators)
+			for _, v := range validators {
+				indices = append(indices, v.Index)
+			}
+			return fmt.Sprintf("%d of %d validators found", len(validators), len(config.Validators)), err
+		})
+	}
+	slot, started := chainClock.CurrentSlot()
+	switch {
+	case len(indices) == 0:
+		t.skip("GetValidatorPerformance", "no validators found")
+	case !started || chainClock.EpochOf(slot) < 2:
+		t.skip("GetValidatorPerformance", "no finished epoch to evaluate yet")
+	default:
+		epoch := chainClock.EpochOf(slot) - 2
+		t.run("GetValidatorPerformance", telemetry.PerformanceTimeout, func(ctx context.Context) (string, error) {
+			performance, err := client.GetValidatorPerformance(ctx, epoch, indices)
+			included := 0
+			for _, p := range performance {
+				if p.AttestationIncluded {
+					included++
+				}
+			}
+			return fmt.Sprintf("epoch %d: %d of %d attestations included", epoch, included, len(performance)), err
+		})
+	}
+
+	t.run("SubscribeChainHeads", headsTimeout, func(ctx context.Context) (string, error) {
+		sub, err := client.SubscribeChainHeads(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer sub.Close()
+
+		var slots []string
+		for len(slots) < heads {
+			select {
+			case head, ok := <-sub.Channel():
+				if !ok {
+					return "", fmt.Errorf("subscription closed after %d heads", len(slots))
+				}
+				slots = append(slots, fmt.Sprint(head.HeadSlot))
+			case <-ctx.Done():
+				return "", fmt.Errorf("got %d of %d heads in %s", len(slots), heads, headsTimeout)
+			}
+		}
+		return fmt.Sprintf("heads at slots %s", strings.Join(slots, ", ")), nil
+	})
+	return t.flush()
+}
+
+func checkMetricsAddr(w io.Writer, config core.Config) bool {
+	t := newCheckTable(w, fmt.Sprintf("node %q, metrics %s", config.Eth2stats.NodeName, config.BeaconNode.MetricsAddr))
+	defer fmt.Fprintln(w)
+
+	watcher := metricsWatcher.New(metricsWatcher.Config{
+		MetricsURL:  config.BeaconNode.MetricsAddr,
+		PollTimeout: config.BeaconNode.MetricsTimeout,
+		DialTimeout: config.BeaconNode.MetricsDialTimeout,
+	})
+	t.run("scrape", config.BeaconNode.MetricsTimeout, func(ctx context.Context) (string, error) {
+		memUsage, err := watcher.Scrape(ctx)
+		if err != nil {
+			return "", err
+		}
+		if memUsage == nil {
+			return "", fmt.Errorf("no process_resident_memory_bytes in metrics")
+		}
+		return fmt.Sprintf("memory usage %d bytes", *memUsage), nil
+	})
+	return t.flush()
+}
+
+func init() {
+	checkCmd.Flags().String("node", "", "Only check the node with this name, of the `nodes` section")
+	checkCmd.Flags().Int("heads", 2, "Number of chain heads to wait for from the subscription")
+	checkCmd.Flags().Duration("heads-timeout", 2*time.Minute, "How long to wait for the chain heads")
+}
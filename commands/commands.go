@@ -8,6 +8,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/alethio/eth2stats-client/logging"
 )
 
 var log = logrus.WithField("module", "main")
@@ -48,7 +50,7 @@ var RootCmd = &cobra.Command{
 			log.Info("Could not load config file. Falling back to args. Error: ", err)
 		}
 
-		initLogging()
+		initLogging(cmd.Flags())
 	},
 
 	Run: func(cmd *cobra.Command, args []string) {
@@ -62,6 +64,8 @@ func init() {
 	cobra.OnInitialize(func() {
 		viper.Set("version", RootCmd.Version)
 	})
+	// e.g. BEACON_ADDR for beacon.addr
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.AutomaticEnv()
 
 	// persistent flags
@@ -76,9 +80,23 @@ func init() {
 	RootCmd.PersistentFlags().BoolVar(&fullTimestamps, "logging.full-timestamps", false, "Display full timestamps in interactive consoles")
 	viper.BindPFlag("logging.full-timestamps", RootCmd.Flag("logging.full-timestamps"))
 
+	RootCmd.PersistentFlags().String("logging.format", logging.FormatText, "Log output format [text, json, logfmt]")
+	viper.BindPFlag("logging.format", RootCmd.Flag("logging.format"))
+
+	RootCmd.PersistentFlags().String("logging.files", "", "Write the logs of some modules to files instead, using format \"module=path,module=path\"")
+	viper.BindPFlag("logging.files", RootCmd.Flag("logging.files"))
+
+	RootCmd.PersistentFlags().Int64("logging.max-size", 100, "Size in megabytes at which log files are rotated (0 disables rotation)")
+	viper.BindPFlag("logging.max-size", RootCmd.Flag("logging.max-size"))
+
+	RootCmd.PersistentFlags().Int("logging.max-backups", 3, "Number of rotated log files to keep")
+	viper.BindPFlag("logging.max-backups", RootCmd.Flag("logging.max-backups"))
+
 	// local flags;
 	RootCmd.Flags().BoolVar(&version, "version", false, "Display the current version of this CLI")
 
 	// commands
 	RootCmd.AddCommand(runCmd)
+	RootCmd.AddCommand(configCmd)
+	RootCmd.AddCommand(checkCmd)
 }
@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/alethio/eth2stats-client/core"
+	"github.com/alethio/eth2stats-client/core/sink"
+)
+
+// ReachabilityTimeout bounds every endpoint check of `config validate`.
+const ReachabilityTimeout = 5 * time.Second
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration and the endpoints it points at, exiting non-zero on problems",
+	Run: func(cmd *cobra.Command, args []string) {
+		offline, _ := cmd.Flags().GetBool("offline")
+		if !validateConfig(cmd.OutOrStdout(), !offline) {
+			os.Exit(1)
+		}
+	},
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration and where every value comes from",
+	Run: func(cmd *cobra.Command, args []string) {
+		printConfig(cmd.OutOrStdout(), cmd)
+	},
+}
+
+// report collects the results of the checks of `config validate`.
+type report struct {
+	w      *tabwriter.Writer
+	failed bool
+}
+
+func (r *report) ok(subject, detail string) {
+	fmt.Fprintf(r.w, "  ok\t%s\t%s\n", subject, detail)
+}
+
+func (r *report) fail(subject string, err error) {
+	r.failed = true
+	fmt.Fprintf(r.w, "  FAIL\t%s\t%s\n", subject, err)
+}
+
+func (r *report) check(subject, detail string, err error) {
+	if err != nil {
+		r.fail(subject, err)
+		return
+	}
+	r.ok(subject, detail)
+}
+
+// validateConfig prints a report of the configuration, returning whether it is usable.
+func validateConfig(w io.Writer, reachability bool) bool {
+	r := &report{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+	defer r.w.Flush()
+
+	if file := viper.ConfigFileUsed(); file != "" {
+		fmt.Fprintf(r.w, "config file %s\n", file)
+	} else {
+		fmt.Fprintln(r.w, "no config file, using flags and environment only")
+	}
+
+	_, err := retrySettings()
+	r.check("settings", "intervals and limits are valid", err)
+	configs, err := nodeConfigs()
+	if err != nil {
+		r.fail("settings", err)
+		return false
+	}
+
+	for _, config := range configs {
+		fmt.Fprintf(r.w, "node %q\n", config.Eth2stats.NodeName)
+
+		problems := config.Problems()
+		for _, problem := range problems {
+			r.fail("config", problem)
+		}
+		if len(problems) == 0 {
+			r.ok("config", "types, addresses and files are valid")
+		}
+		if !reachability {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), ReachabilityTimeout)
+		for i, server := range config.Eth2stats.Servers {
+			if config.Eth2stats.Sink != sink.Server {
+				break
+			}
+			r.check(fmt.Sprintf("eth2stats.servers[%d]", i), fmt.Sprintf("%s is reachable", server.Addr),
+				checkServer(ctx, server.Addr, server.TLS, server.TLSConfig))
+		}
+		if config.Eth2stats.Sink == sink.Server && len(config.Eth2stats.Servers) == 0 {
+			r.check("eth2stats.addr", fmt.Sprintf("%s is reachable", config.Eth2stats.ServerAddr),
+				checkServer(ctx, config.Eth2stats.ServerAddr, config.Eth2stats.TLS, config.Eth2stats.TLSConfig))
+		}
+		for _, addr := range config.BeaconNode.Addrs {
+			r.check("beacon.addr", fmt.Sprintf("%s is reachable", addr), checkBeaconAddr(ctx, addr))
+		}
+		if addr := config.BeaconNode.MetricsAddr; addr != "" {
+			r.check("beacon.metrics-addr", fmt.Sprintf("%s serves metrics", addr), checkMetrics(ctx, addr))
+		}
+		cancel()
+	}
+
+	if r.failed {
+		fmt.Fprintln(r.w, "configuration has problems")
+	} else {
+		fmt.Fprintln(r.w, "configuration is valid")
+	}
+	return !r.failed
+}
+
+func checkServer(ctx context.Context, addr string, useTLS bool, tlsConfig core.TLSConfig) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !useTLS {
+		return nil
+	}
+
+	config, err := core.NewTLSConfig(addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	tlsConn := tls.Client(conn, config)
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = tlsConn.SetDeadline(deadline)
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake: %s", err)
+	}
+	return nil
+}
+
+func checkBeaconAddr(ctx context.Context, addr string) error {
+	hostPort := addr
+	if core.IsURL(addr) {
+		u, _ := url.Parse(addr)
+		hostPort = u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			hostPort = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func checkMetrics(ctx context.Context, addr string) error {
+	req, err := http.NewRequest("GET", addr, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// printConfig prints every option with its effective value, in the order of precedence of viper:
+// flags, then environment, then config file, then defaults.
+func printConfig(w io.Writer, cmd *cobra.Command) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	// a separate instance tells which keys are set in the file itself
+	var file *viper.Viper
+	if path := viper.ConfigFileUsed(); path != "" {
+		fmt.Fprintf(tw, "# config file %s\n", path)
+		file = viper.New()
+		file.SetConfigFile(path)
+		if err := file.ReadInConfig(); err != nil {
+			file = nil
+		}
+	}
+
+	keys := make(map[string]bool)
+	for _, key := range viper.AllKeys() {
+		keys[key] = true
+	}
+	// a `logging` string in the config file hides the other logging options from the keys
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if strings.HasPrefix(flag.Name, "logging.") {
+			keys[flag.Name] = true
+		}
+	})
+	if viper.IsSet("logging.levels") {
+		keys["logging.levels"] = true
+	}
+	// set by the client itself, not configurable
+	delete(keys, "version")
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		var value interface{}
+		if strings.HasPrefix(key, "logging.") {
+			value = loggingOption(cmd.Flags(), key)
+		} else {
+			value = viper.Get(key)
+		}
+		if _, ok := value.(map[string]interface{}); ok {
+			// a section, whose options are listed on their own
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%v\t(%s)\n", key, value, configSource(cmd, file, key))
+	}
+}
+
+func configSource(cmd *cobra.Command, file *viper.Viper, key string) string {
+	if flag := cmd.Flags().Lookup(key); flag != nil && flag.Changed {
+		return "flag"
+	}
+	if _, ok := os.LookupEnv(strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))); ok {
+		return "env"
+	}
+	if file != nil && file.IsSet(key) {
+		return "config file"
+	}
+	return "default"
+}
+
+func init() {
+	configValidateCmd.Flags().Bool("offline", false, "Only check the configuration itself, without contacting any endpoint")
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configPrintCmd)
+}
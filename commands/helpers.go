@@ -1,30 +1,50 @@
 package commands
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	formatter "github.com/kwix/logrus-module-formatter"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	"github.com/alethio/eth2stats-client/logging"
 )
 
-func initLogging() {
-	logging := viper.GetString("logging")
+// loggingOption reads one of the logging options. A `logging` string in the config file hides them from
+// viper, in which case the flag, set or not, still tells.
+func loggingOption(flags *pflag.FlagSet, key string) string {
+	if viper.IsSet(key) {
+		return viper.GetString(key)
+	}
+	return flags.Lookup(key).Value.String()
+}
+
+func initLogging(flags *pflag.FlagSet) {
+	levels := viper.GetString("logging")
+	if levels == "" {
+		// config files set the other logging options in a `logging` section, with the module levels as `levels`
+		levels = viper.GetString("logging.levels")
+	}
 
 	if verbose {
-		logging = "*=debug"
+		levels = "*=debug"
 	}
 
 	if vverbose {
-		logging = "*=trace"
+		levels = "*=trace"
 	}
 
-	if logging == "" {
-		logging = "*=info"
+	if levels == "" {
+		levels = "*=info"
 	}
 
 	gin.SetMode(gin.DebugMode)
 
-	modules := formatter.NewModulesMap(logging)
+	modules := formatter.NewModulesMap(levels)
 	if level, exists := modules["gin"]; exists {
 		if level < logrus.DebugLevel {
 			gin.SetMode(gin.ReleaseMode)
@@ -35,15 +55,69 @@ func initLogging() {
 			gin.SetMode(gin.ReleaseMode)
 		}
 	}
-	logrusFormatter := &logrus.TextFormatter{
-		FullTimestamp: fullTimestamps,
+
+	files, err := logging.ParseFiles(loggingOption(flags, "logging.files"))
+	if err != nil {
+		log.Fatalf("invalid --logging.files: %s", err)
 	}
-	f, err := formatter.NewWithFormatter(modules, logrusFormatter)
+	err = logging.Setup(logging.Config{
+		Levels:         modules,
+		Format:         loggingOption(flags, "logging.format"),
+		FullTimestamps: fullTimestamps,
+		Files:          files,
+		MaxSize:        cast.ToInt64(loggingOption(flags, "logging.max-size")) * 1024 * 1024,
+		MaxBackups:     cast.ToInt(loggingOption(flags, "logging.max-backups")),
+	})
 	if err != nil {
-		panic(err)
+		log.Fatalf("setting up logging: %s", err)
 	}
 
-	logrus.SetFormatter(f)
-
 	log.Debug("Debug mode")
 }
+
+// settings reads numeric settings from viper, keeping the first invalid one as error.
+type settings struct {
+	err error
+}
+
+func (s *settings) invalid(key string, requirement string) {
+	if s.err == nil {
+		s.err = fmt.Errorf("%s must be %s, got %q", key, requirement, viper.GetString(key))
+	}
+}
+
+func (s *settings) positiveDuration(key string) time.Duration {
+	d, err := cast.ToDurationE(viper.Get(key))
+	if err != nil || d <= 0 {
+		s.invalid(key, "a positive duration")
+	}
+	return d
+}
+
+func (s *settings) nonNegativeDuration(key string) time.Duration {
+	d, err := cast.ToDurationE(viper.Get(key))
+	if err != nil || d < 0 {
+		s.invalid(key, "a duration of at least 0")
+	}
+	return d
+}
+
+func (s *settings) positiveInt(key string) int {
+	i, err := cast.ToIntE(viper.Get(key))
+	if err != nil || i <= 0 {
+		s.invalid(key, "a positive number")
+	}
+	return i
+}
+
+func (s *settings) positiveUint64(key string) uint64 {
+	return uint64(s.positiveInt(key))
+}
+
+func (s *settings) positiveFloat(key string) float64 {
+	f, err := cast.ToFloat64E(viper.Get(key))
+	if err != nil || f <= 0 {
+		s.invalid(key, "a positive number")
+	}
+	return f
+}
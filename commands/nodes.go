@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/alethio/eth2stats-client/core"
+)
+
+// nodeConfig is a single entry of the `nodes` config section.
+type nodeConfig struct {
+	NodeName   string   `mapstructure:"node-name"`
+	TokenFile  string   `mapstructure:"token-file"`
+	Validators []string `mapstructure:"validators"`
+	Beacon     struct {
+		Type        string   `mapstructure:"type"`
+		Addrs       []string `mapstructure:"addr"`
+		TLSCert     string   `mapstructure:"tls-cert"`
+		MetricsAddr string   `mapstructure:"metrics-addr"`
+	} `mapstructure:"beacon"`
+}
+
+// serverConfig is a single entry of the `eth2stats.servers` config section.
+type serverConfig struct {
+	Addr          string `mapstructure:"addr"`
+	TLS           *bool  `mapstructure:"tls"`
+	TLSCA         string `mapstructure:"tls-ca"`
+	TLSCert       string `mapstructure:"tls-cert"`
+	TLSKey        string `mapstructure:"tls-key"`
+	TLSServerName string `mapstructure:"tls-server-name"`
+	TLSMinVersion string `mapstructure:"tls-min-version"`
+	NodeName      string `mapstructure:"node-name"`
+	TokenFile     string `mapstructure:"token-file"`
+}
+
+// node keeps track of the core currently reporting a configured beacon node.
+type node struct {
+	config core.Config
+
+	mu   sync.Mutex
+	core *core.Core
+	err  error
+}
+
+func newNode(config core.Config) *node {
+	// the cores of a node come and go with its connections, what is learned about it stays
+	config.History = core.NewHistory(config)
+	return &node{
+		config: config,
+	}
+}
+
+func (n *node) setCore(c *core.Core) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.core = c
+	n.err = nil
+}
+
+func (n *node) setError(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.core = nil
+	n.err = err
+}
+
+// Status returns the status of the current core, or why there is none.
+func (n *node) Status() core.Status {
+	n.mu.Lock()
+	c, err := n.core, n.err
+	n.mu.Unlock()
+
+	if c != nil {
+		return c.Status()
+	}
+	status := core.Status{
+		NodeName: n.config.Eth2stats.NodeName,
+	}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	return status
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// nodeConfigs returns one core config per beacon node to report.
+// Without a `nodes` section, the single node configured through the `beacon` flags is used.
+func nodeConfigs() ([]core.Config, error) {
+	var s settings
+	eth2stats := core.Eth2statsConfig{
+		Version:    fmt.Sprintf("eth2stats-client/%s", RootCmd.Version),
+		ServerAddr: viper.GetString("eth2stats.addr"),
+		TLS:        viper.GetBool("eth2stats.tls"),
+		TLSConfig: core.TLSConfig{
+			CAFile:     viper.GetString("eth2stats.tls-ca"),
+			CertFile:   viper.GetString("eth2stats.tls-cert"),
+			KeyFile:    viper.GetString("eth2stats.tls-key"),
+			ServerName: viper.GetString("eth2stats.tls-server-name"),
+			MinVersion: viper.GetString("eth2stats.tls-min-version"),
+		},
+		NodeName:           viper.GetString("eth2stats.node-name"),
+		Sink:               viper.GetString("sink"),
+		HeartbeatInterval:  s.positiveDuration("eth2stats.heartbeat-interval"),
+		ChainHeadRateLimit: s.positiveFloat("eth2stats.chain-head-rate-limit"),
+		ChainHeadBurst:     s.positiveInt("eth2stats.chain-head-burst"),
+	}
+	dataFolder := viper.GetString("data.folder")
+	bufferConfig := core.BufferConfig{
+		Enabled:    viper.GetBool("buffer.enabled"),
+		MaxEntries: s.positiveInt("buffer.max-entries"),
+		MaxAge:     s.positiveDuration("buffer.max-age"),
+		Replay:     viper.GetString("buffer.replay"),
+	}
+
+	chainConfig := core.ChainConfig{
+		SecondsPerSlot:   s.positiveUint64("chain.seconds-per-slot"),
+		SlotsPerEpoch:    s.positiveUint64("chain.slots-per-epoch"),
+		PollOffset:       s.nonNegativeDuration("chain.poll-offset"),
+		HeadPollInterval: s.positiveDuration("chain.head-poll-interval"),
+
+		FinalityStalledEpochs: s.positiveUint64("chain.finality-stalled-epochs"),
+		HeadLaggingSlots:      s.positiveUint64("chain.head-lagging-slots"),
+	}
+	telemetryConfig := core.TelemetryConfig{
+		PollingInterval:      s.positiveDuration("telemetry.polling-interval"),
+		MemoryUsageThreshold: int64(s.positiveInt("telemetry.memory-usage-threshold")),
+		ChurnWindow:          s.positiveDuration("telemetry.churn-window"),
+		LongLivedPeerAge:     s.positiveDuration("telemetry.long-lived-peer-age"),
+	}
+	metricsPollInterval := s.positiveDuration("beacon.metrics-poll-interval")
+	metricsTimeout := s.positiveDuration("beacon.metrics-timeout")
+	metricsDialTimeout := s.positiveDuration("beacon.metrics-dial-timeout")
+	metricsRetryAttempts := uint(s.positiveInt("beacon.metrics-retry-attempts"))
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var nodes []nodeConfig
+	err := viper.UnmarshalKey("nodes", &nodes)
+	if err != nil {
+		return nil, fmt.Errorf("reading nodes config: %s", err)
+	}
+
+	var servers []serverConfig
+	err = viper.UnmarshalKey("eth2stats.servers", &servers)
+	if err != nil {
+		return nil, fmt.Errorf("reading servers config: %s", err)
+	}
+	if len(servers) > 0 {
+		retry, err := retrySettings()
+		if err != nil {
+			return nil, err
+		}
+		eth2stats.RetryInterval = retry.Interval
+		eth2stats.MaxRetryInterval = retry.MaxInterval
+	}
+	for i, server := range servers {
+		// several nodes would all report under the same name and token
+		if len(nodes) > 0 && server.NodeName != "" {
+			return nil, fmt.Errorf("eth2stats.servers[%d]: node-name can't be used with the nodes section", i)
+		}
+		if len(nodes) > 0 && server.TokenFile != "" {
+			return nil, fmt.Errorf("eth2stats.servers[%d]: token-file can't be used with the nodes section", i)
+		}
+		// like --eth2stats.tls, entries connect securely unless told otherwise
+		useTLS := server.TLS == nil || *server.TLS
+		eth2stats.Servers = append(eth2stats.Servers, core.ServerConfig{
+			Addr: server.Addr,
+			TLS:  useTLS,
+			TLSConfig: core.TLSConfig{
+				CAFile:     server.TLSCA,
+				CertFile:   server.TLSCert,
+				KeyFile:    server.TLSKey,
+				ServerName: server.TLSServerName,
+				MinVersion: server.TLSMinVersion,
+			},
+			NodeName:  server.NodeName,
+			TokenFile: server.TokenFile,
+		})
+	}
+
+	if len(nodes) == 0 {
+		return []core.Config{{
+			Eth2stats: eth2stats,
+			BeaconNode: core.BeaconNodeConfig{
+				Type:                 viper.GetString("beacon.type"),
+				Addrs:                viper.GetStringSlice("beacon.addr"),
+				TLSCert:              viper.GetString("beacon.tls-cert"),
+				MetricsAddr:          viper.GetString("beacon.metrics-addr"),
+				MetricsPollInterval:  metricsPollInterval,
+				MetricsTimeout:       metricsTimeout,
+				MetricsDialTimeout:   metricsDialTimeout,
+				MetricsRetryAttempts: metricsRetryAttempts,
+			},
+			Chain:      chainConfig,
+			Telemetry:  telemetryConfig,
+			Buffer:     bufferConfig,
+			DataFolder: dataFolder,
+			Validators: viper.GetStringSlice("validators"),
+		}}, nil
+	}
+
+	configs := make([]core.Config, 0, len(nodes))
+	names := make(map[string]bool)
+	tokenFiles := make(map[string]bool)
+	for i, node := range nodes {
+		if node.NodeName == "" {
+			return nil, fmt.Errorf("nodes[%d]: missing node-name", i)
+		}
+		if names[node.NodeName] {
+			return nil, fmt.Errorf("nodes[%d]: duplicate node-name %q", i, node.NodeName)
+		}
+		names[node.NodeName] = true
+
+		// every node registers separately, so they can't share a token
+		tokenFile := node.TokenFile
+		if tokenFile == "" {
+			tokenFile = fmt.Sprintf("token-%s.dat", unsafeFileChars.ReplaceAllString(node.NodeName, "_"))
+		}
+		if tokenFiles[tokenFile] {
+			return nil, fmt.Errorf("nodes[%d]: token-file %q is used by another node", i, tokenFile)
+		}
+		tokenFiles[tokenFile] = true
+
+		nodeEth2stats := eth2stats
+		nodeEth2stats.NodeName = node.NodeName
+		configs = append(configs, core.Config{
+			Eth2stats: nodeEth2stats,
+			BeaconNode: core.BeaconNodeConfig{
+				Type:                 node.Beacon.Type,
+				Addrs:                node.Beacon.Addrs,
+				TLSCert:              node.Beacon.TLSCert,
+				MetricsAddr:          node.Beacon.MetricsAddr,
+				MetricsPollInterval:  metricsPollInterval,
+				MetricsTimeout:       metricsTimeout,
+				MetricsDialTimeout:   metricsDialTimeout,
+				MetricsRetryAttempts: metricsRetryAttempts,
+			},
+			Chain:      chainConfig,
+			Telemetry:  telemetryConfig,
+			Buffer:     bufferConfig,
+			DataFolder: dataFolder,
+			TokenFile:  tokenFile,
+			Validators: node.Validators,
+		})
+	}
+	return configs, nil
+}
+
+// retryConfig is the backoff of reconnecting nodes.
+type retryConfig struct {
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+func retrySettings() (retryConfig, error) {
+	var s settings
+	retry := retryConfig{
+		Interval:    s.positiveDuration("eth2stats.retry-interval"),
+		MaxInterval: s.positiveDuration("eth2stats.max-retry-interval"),
+	}
+	if s.err != nil {
+		return retry, s.err
+	}
+	if retry.MaxInterval < retry.Interval {
+		return retry, fmt.Errorf("eth2stats.max-retry-interval must be at least eth2stats.retry-interval")
+	}
+	return retry, nil
+}
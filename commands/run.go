@@ -2,19 +2,31 @@ package commands
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/alethio/eth2stats-client/api"
+	"github.com/alethio/eth2stats-client/beacon/polling"
 	"github.com/alethio/eth2stats-client/core"
+	"github.com/alethio/eth2stats-client/core/buffer"
+	"github.com/alethio/eth2stats-client/core/finality"
+	"github.com/alethio/eth2stats-client/core/sink"
+	"github.com/alethio/eth2stats-client/core/telemetry"
+	"github.com/alethio/eth2stats-client/exporter"
+	metricsWatcher "github.com/alethio/eth2stats-client/watcher/metrics"
 )
 
-const RetryInterval = time.Second * 12
+// Defaults of the reconnect backoff.
+const (
+	RetryInterval    = time.Second * 12
+	MaxRetryInterval = time.Minute * 5
+)
 
 var runCmd = &cobra.Command{
 	Use:   "run",
@@ -36,52 +48,116 @@ var runCmd = &cobra.Command{
 			}
 		}()
 
-	workLoop:
-		for {
-			c := core.New(core.Config{
-				Eth2stats: core.Eth2statsConfig{
-					Version:    fmt.Sprintf("eth2stats-client/%s", RootCmd.Version),
-					ServerAddr: viper.GetString("eth2stats.addr"),
-					TLS:        viper.GetBool("eth2stats.tls"),
-					NodeName:   viper.GetString("eth2stats.node-name"),
-				},
-				BeaconNode: core.BeaconNodeConfig{
-					Type:        viper.GetString("beacon.type"),
-					Addr:        viper.GetString("beacon.addr"),
-					TLSCert:     viper.GetString("beacon.tls-cert"),
-					MetricsAddr: viper.GetString("beacon.metrics-addr"),
-				},
-				DataFolder: viper.GetString("data.folder"),
-			})
-
-			err := c.Run(ctx)
-
-			// Check if the service needs to stop yet.
-			select {
-			case <-ctx.Done():
-				break workLoop
-			default:
-			}
+		configs, err := nodeConfigs()
+		if err != nil {
+			log.Fatal(err)
+		}
+		retry, err := retrySettings()
+		if err != nil {
+			log.Fatal(err)
+		}
 
-			if err == nil {
-				log.Warn("eth2stats work stopped unexpectedly without error")
-			} else {
-				log.Error(err)
-			}
+		nodes := make([]*node, 0, len(configs))
+		for _, config := range configs {
+			nodes = append(nodes, newNode(config))
+		}
 
-			// we're only getting here if there's been a setup error that is recoverable
-			log.Infof("retrying in %s...", RetryInterval)
-			select {
-			case <-ctx.Done():
-				break workLoop
-			case <-time.After(RetryInterval):
+		if addr := viper.GetString("api.addr"); addr != "" {
+			providers := make([]api.StatusProvider, 0, len(nodes))
+			for _, n := range nodes {
+				providers = append(providers, n)
 			}
+			server := api.New(api.Config{
+				Addr: addr,
+				// the heartbeat interval is shared by all nodes
+				HeartbeatInterval: configs[0].Eth2stats.HeartbeatInterval,
+			}, providers)
+			go func() {
+				err := server.Run(ctx)
+				if err != nil {
+					log.Fatalf("serving status api: %s", err)
+				}
+			}()
+		}
+
+		var wg sync.WaitGroup
+		for _, n := range nodes {
+			wg.Add(1)
+			go func(n *node) {
+				defer wg.Done()
+				err := runNode(ctx, n, retry)
+				if err != nil {
+					n.setError(err)
+					log.WithField("node", n.config.Eth2stats.NodeName).Errorf("giving up on node: %s", err)
+				}
+			}(n)
+		}
+		wg.Wait()
+
+		// nodes only stop on their own when they can't be set up at all
+		if ctx.Err() == nil {
+			log.Fatal("no nodes left to report")
 		}
 
 		log.Info("work done. goodbye!")
 	},
 }
 
+// runNode keeps reporting a single beacon node, reconnecting with backoff until the context is cancelled.
+// It only returns an error if the node can't be set up from its configuration.
+func runNode(ctx context.Context, n *node, retry retryConfig) error {
+	nodeLog := log.WithField("node", n.config.Eth2stats.NodeName)
+	metrics := exporter.ForNode(n.config.Eth2stats.NodeName)
+	retryInterval := retry.Interval
+
+	for {
+		c, err := core.New(n.config)
+		if err != nil {
+			return err
+		}
+		n.setCore(c)
+
+		started := time.Now()
+		err = c.Run(ctx)
+
+		// Check if the service needs to stop yet.
+		select {
+		case <-ctx.Done():
+			c.Close()
+			return nil
+		default:
+		}
+
+		if err == nil {
+			nodeLog.Warn("eth2stats work stopped unexpectedly without error")
+		} else {
+			nodeLog.Error(err)
+		}
+
+		// a run that lasted a while was healthy, so don't hold earlier failures against it
+		if time.Since(started) > retry.MaxInterval {
+			retryInterval = retry.Interval
+		}
+
+		// we're only getting here if there's been an error that is recoverable
+		nodeLog.Infof("retrying in %s...", retryInterval)
+		waitCtx, cancelWait := context.WithTimeout(ctx, retryInterval)
+		c.Record(waitCtx)
+		<-waitCtx.Done()
+		cancelWait()
+		c.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		metrics.Reconnects.Inc()
+
+		retryInterval *= 2
+		if retryInterval > retry.MaxInterval {
+			retryInterval = retry.MaxInterval
+		}
+	}
+}
+
 func init() {
 	runCmd.Flags().String("eth2stats.addr", "", "Eth2stats server address")
 	viper.BindPFlag("eth2stats.addr", runCmd.Flag("eth2stats.addr"))
@@ -92,10 +168,43 @@ func init() {
 	runCmd.Flags().Bool("eth2stats.tls", true, "Enable/disable TLS for eth2stats server connection")
 	viper.BindPFlag("eth2stats.tls", runCmd.Flag("eth2stats.tls"))
 
-	runCmd.Flags().String("beacon.type", "", "Beacon node type [prysm, lighthouse, teku, nimbus, v1]")
+	runCmd.Flags().String("eth2stats.tls-ca", "", "PEM bundle of the CAs to trust for the eth2stats server instead of the system roots")
+	viper.BindPFlag("eth2stats.tls-ca", runCmd.Flag("eth2stats.tls-ca"))
+
+	runCmd.Flags().String("eth2stats.tls-cert", "", "PEM client certificate for mutual TLS with the eth2stats server")
+	viper.BindPFlag("eth2stats.tls-cert", runCmd.Flag("eth2stats.tls-cert"))
+
+	runCmd.Flags().String("eth2stats.tls-key", "", "PEM key of the client certificate")
+	viper.BindPFlag("eth2stats.tls-key", runCmd.Flag("eth2stats.tls-key"))
+
+	runCmd.Flags().String("eth2stats.tls-server-name", "", "Name to verify the eth2stats server certificate against (default: the host of eth2stats.addr)")
+	viper.BindPFlag("eth2stats.tls-server-name", runCmd.Flag("eth2stats.tls-server-name"))
+
+	runCmd.Flags().String("eth2stats.tls-min-version", "", "Minimum TLS version of the eth2stats server connection: 1.0, 1.1, 1.2 or 1.3 (default: Go's default)")
+	viper.BindPFlag("eth2stats.tls-min-version", runCmd.Flag("eth2stats.tls-min-version"))
+
+	runCmd.Flags().String("sink", sink.Server, "Where to send the data: \"server\" (the eth2stats server), \"stdout\" or \"file:<path>\" to write JSON lines instead")
+	viper.BindPFlag("sink", runCmd.Flag("sink"))
+
+	runCmd.Flags().Duration("eth2stats.heartbeat-interval", core.HeartbeatInterval, "How often to send heartbeats to the eth2stats server")
+	viper.BindPFlag("eth2stats.heartbeat-interval", runCmd.Flag("eth2stats.heartbeat-interval"))
+
+	runCmd.Flags().Float64("eth2stats.chain-head-rate-limit", core.ChainHeadRateLimit, "Maximum number of chain heads sent to the eth2stats server per second")
+	viper.BindPFlag("eth2stats.chain-head-rate-limit", runCmd.Flag("eth2stats.chain-head-rate-limit"))
+
+	runCmd.Flags().Int("eth2stats.chain-head-burst", core.ChainHeadBurst, "Number of chain heads that may be sent at once, above the rate limit")
+	viper.BindPFlag("eth2stats.chain-head-burst", runCmd.Flag("eth2stats.chain-head-burst"))
+
+	runCmd.Flags().Duration("eth2stats.retry-interval", RetryInterval, "How long to wait before reconnecting after a failure; doubled on every failure in a row")
+	viper.BindPFlag("eth2stats.retry-interval", runCmd.Flag("eth2stats.retry-interval"))
+
+	runCmd.Flags().Duration("eth2stats.max-retry-interval", MaxRetryInterval, "Maximum time to wait before reconnecting")
+	viper.BindPFlag("eth2stats.max-retry-interval", runCmd.Flag("eth2stats.max-retry-interval"))
+
+	runCmd.Flags().String("beacon.type", "", "Beacon node type [auto, prysm, lighthouse, teku, nimbus, v1]")
 	viper.BindPFlag("beacon.type", runCmd.Flag("beacon.type"))
 
-	runCmd.Flags().String("beacon.addr", "", "Beacon node endpoint address")
+	runCmd.Flags().StringSlice("beacon.addr", nil, "Beacon node endpoint address; several comma separated addresses of redundant nodes are used in order, failing over to the next")
 	viper.BindPFlag("beacon.addr", runCmd.Flag("beacon.addr"))
 
 	runCmd.Flags().String("beacon.tls-cert", "", "Beacon node certificate to secure gRPC connection")
@@ -104,6 +213,71 @@ func init() {
 	runCmd.Flags().String("beacon.metrics-addr", "", "The url where the beacon client exposes metrics (used for memory usage)")
 	viper.BindPFlag("beacon.metrics-addr", runCmd.Flag("beacon.metrics-addr"))
 
+	runCmd.Flags().Duration("beacon.metrics-poll-interval", metricsWatcher.PollingInterval, "How often to query the metrics of the beacon node")
+	viper.BindPFlag("beacon.metrics-poll-interval", runCmd.Flag("beacon.metrics-poll-interval"))
+
+	runCmd.Flags().Duration("beacon.metrics-timeout", metricsWatcher.PollTimeout, "Timeout of a metrics query")
+	viper.BindPFlag("beacon.metrics-timeout", runCmd.Flag("beacon.metrics-timeout"))
+
+	runCmd.Flags().Duration("beacon.metrics-dial-timeout", metricsWatcher.PollDialTimeout, "Timeout of connecting to the metrics endpoint, and of the TLS handshake")
+	viper.BindPFlag("beacon.metrics-dial-timeout", runCmd.Flag("beacon.metrics-dial-timeout"))
+
+	runCmd.Flags().Uint("beacon.metrics-retry-attempts", metricsWatcher.PollRetryAttempts, "Number of attempts of a metrics query")
+	viper.BindPFlag("beacon.metrics-retry-attempts", runCmd.Flag("beacon.metrics-retry-attempts"))
+
+	runCmd.Flags().Uint64("chain.seconds-per-slot", 12, "Duration of a slot, in seconds, if the beacon node does not serve its spec")
+	viper.BindPFlag("chain.seconds-per-slot", runCmd.Flag("chain.seconds-per-slot"))
+
+	runCmd.Flags().Uint64("chain.slots-per-epoch", 32, "Number of slots in an epoch, if the beacon node does not serve its spec")
+	viper.BindPFlag("chain.slots-per-epoch", runCmd.Flag("chain.slots-per-epoch"))
+
+	runCmd.Flags().Duration("chain.poll-offset", 4*time.Second, "How far into each slot to poll the beacon node, to give blocks time to arrive")
+	viper.BindPFlag("chain.poll-offset", runCmd.Flag("chain.poll-offset"))
+
+	runCmd.Flags().StringSlice("validators", nil, "Comma separated indices or 0x-prefixed pubkeys of validators whose balance, status and duties to watch")
+	viper.BindPFlag("validators", runCmd.Flag("validators"))
+
+	runCmd.Flags().Duration("chain.head-poll-interval", polling.PollingInterval, "How often to poll nodes without an event stream for the chain head while it lags behind the current slot")
+	viper.BindPFlag("chain.head-poll-interval", runCmd.Flag("chain.head-poll-interval"))
+
+	runCmd.Flags().Uint64("chain.finality-stalled-epochs", finality.StalledEpochs, "Epochs since the finalized checkpoint from which finality is reported as stalled")
+	viper.BindPFlag("chain.finality-stalled-epochs", runCmd.Flag("chain.finality-stalled-epochs"))
+
+	runCmd.Flags().Uint64("chain.head-lagging-slots", finality.LaggingSlots, "Slots behind the current slot from which the chain head is reported as lagging")
+	viper.BindPFlag("chain.head-lagging-slots", runCmd.Flag("chain.head-lagging-slots"))
+
+	runCmd.Flags().Duration("telemetry.polling-interval", telemetry.PollingInterval, "How often to poll telemetry until the slot timing of the chain is known")
+	viper.BindPFlag("telemetry.polling-interval", runCmd.Flag("telemetry.polling-interval"))
+
+	runCmd.Flags().Int64("telemetry.memory-usage-threshold", telemetry.MemoryUsageThreshold, "Change in memory usage, in bytes, from which it is sent again")
+	viper.BindPFlag("telemetry.memory-usage-threshold", runCmd.Flag("telemetry.memory-usage-threshold"))
+
+	runCmd.Flags().Duration("telemetry.churn-window", telemetry.ChurnWindow, "Period peer connects and disconnects per minute are averaged over")
+	viper.BindPFlag("telemetry.churn-window", runCmd.Flag("telemetry.churn-window"))
+
+	runCmd.Flags().Duration("telemetry.long-lived-peer-age", telemetry.LongLivedPeerAge, "How long a peer has to be connected to count as long-lived")
+	viper.BindPFlag("telemetry.long-lived-peer-age", runCmd.Flag("telemetry.long-lived-peer-age"))
+
 	runCmd.Flags().String("data.folder", "./data", "Folder in which to persist data")
 	viper.BindPFlag("data.folder", runCmd.Flag("data.folder"))
+
+	runCmd.Flags().Bool("buffer.enabled", false, "Buffer data on disk while the eth2stats server is unreachable and replay it after reconnecting")
+	viper.BindPFlag("buffer.enabled", runCmd.Flag("buffer.enabled"))
+
+	runCmd.Flags().Int("buffer.max-entries", 1000, "Maximum number of buffered entries; the oldest are dropped first")
+	viper.BindPFlag("buffer.max-entries", runCmd.Flag("buffer.max-entries"))
+
+	runCmd.Flags().Duration("buffer.max-age", time.Hour, "Maximum age of buffered entries")
+	viper.BindPFlag("buffer.max-age", runCmd.Flag("buffer.max-age"))
+
+	runCmd.Flags().String("buffer.replay", buffer.ReplayOrdered, "How to replay buffered entries [ordered, latest]")
+	viper.BindPFlag("buffer.replay", runCmd.Flag("buffer.replay"))
+
+	runCmd.Flags().String("api.addr", "", "Address to serve the local status, health and metrics api on, e.g. \":8081\" (disabled if empty)")
+	viper.BindPFlag("api.addr", runCmd.Flag("api.addr"))
+
+	// the config and check commands see the same options as run
+	configValidateCmd.Flags().AddFlagSet(runCmd.Flags())
+	configPrintCmd.Flags().AddFlagSet(runCmd.Flags())
+	checkCmd.Flags().AddFlagSet(runCmd.Flags())
 }
@@ -1,37 +1,126 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"time"
 
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/failover"
 	"github.com/alethio/eth2stats-client/beacon/lighthouse"
 	"github.com/alethio/eth2stats-client/beacon/nimbus"
 	"github.com/alethio/eth2stats-client/beacon/prysm"
 	"github.com/alethio/eth2stats-client/beacon/teku"
 	"github.com/alethio/eth2stats-client/beacon/v1"
+	"github.com/alethio/eth2stats-client/clock"
 )
 
-func initBeaconClient(nodeType, nodeAddr, nodeCert string) beacon.Client {
+// setUpBeaconClient sets up a client for every node address, behind a failover client if there are several.
+// With AutoDetect, addresses whose type can't be detected are left out as long as one of them can be used.
+func (c *Core) setUpBeaconClient(ctx context.Context) error {
+	if len(c.config.BeaconNode.Addrs) == 0 {
+		return errors.New("missing beacon node address")
+	}
+
+	var backends []failover.Backend
+	var detectErr error
+	for _, addr := range c.config.BeaconNode.Addrs {
+		if c.config.BeaconNode.Type != AutoDetect {
+			client, err := initBeaconClient(c.config.BeaconNode.Type, addr, c.config.BeaconNode.TLSCert, c.clock)
+			if err != nil {
+				return err
+			}
+			backends = append(backends, failover.Backend{Name: addr, Client: client})
+			continue
+		}
+
+		client, err := c.detectBeaconClient(ctx, addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			c.log.WithField("addr", addr).Warnf("detecting node type: %s", err)
+			detectErr = err
+			continue
+		}
+		backends = append(backends, failover.Backend{Name: addr, Client: client})
+	}
+	if len(backends) == 0 {
+		return detectErr
+	}
+
+	var client beacon.Client = backends[0].Client
+	if len(c.config.BeaconNode.Addrs) > 1 {
+		client = failover.New(backends)
+	}
+	c.statusMu.Lock()
+	c.beaconClient = client
+	c.statusMu.Unlock()
+	return nil
+}
+
+// NewBeaconClient sets up a client for a single address of the beacon node, detecting its type with AutoDetect,
+// without anything else a Core needs. The returned clock is the one the client schedules by; it starts
+// without genesis time.
+func NewBeaconClient(ctx context.Context, config Config, addr string) (beacon.Client, *clock.Clock, error) {
+	c := &Core{
+		config: config,
+		log:    log.WithField("node", config.Eth2stats.NodeName),
+		clock:  newClock(config.Chain),
+	}
+	if config.BeaconNode.Type == AutoDetect {
+		client, err := c.detectBeaconClient(ctx, addr)
+		return client, c.clock, err
+	}
+	client, err := initBeaconClient(config.BeaconNode.Type, addr, config.BeaconNode.TLSCert, c.clock)
+	return client, c.clock, err
+}
+
+func initBeaconClient(nodeType, nodeAddr, nodeCert string, chainClock *clock.Clock) (beacon.Client, error) {
 	// check GRPC clients
 	switch nodeType {
 	case "prysm":
-		return prysm.New(prysm.Config{GRPCAddr: nodeAddr, TLSCert: nodeCert})
+		client, err := prysm.New(prysm.Config{GRPCAddr: nodeAddr, TLSCert: nodeCert})
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
 	default:
 		break
 	}
 
 	// If not GRPC, then default to HTTP
-	// FIXME: For clients with multiple supported types, enable the user to select the type.
+	httpClient, err := newHTTPClient(nodeAddr, nodeCert)
+	if err != nil {
+		return nil, err
+	}
+
+	switch nodeType {
+	case "lighthouse":
+		return lighthouse.New(httpClient, nodeAddr, chainClock), nil
+	case "teku":
+		return teku.New(httpClient, nodeAddr, chainClock), nil
+	case "nimbus":
+		return nimbus.New(httpClient, nodeAddr, chainClock), nil
+	case "v1":
+		return v1.New(httpClient, nodeAddr, chainClock), nil
+	default:
+		return nil, fmt.Errorf("node type not recognized: %s", nodeType)
+	}
+}
+
+func newHTTPClient(nodeAddr, nodeCert string) (*http.Client, error) {
 	if !IsURL(nodeAddr) {
-		log.Fatalf("invalid node URL: %s", nodeAddr)
+		return nil, fmt.Errorf("invalid node URL: %s", nodeAddr)
 	}
 	// Custom TLS certificates have only been tested with GRPC connections.
 	// Fail early here to avoid mistakes. This can be implemented later if needed.
 	if nodeCert != "" {
-		log.Fatal("custom TLS certificates are currently only supported for GRPC connections")
+		return nil, errors.New("custom TLS certificates are currently only supported for GRPC connections")
 	}
 	var netTransport = &http.Transport{
 		DialContext: (&net.Dialer{
@@ -39,24 +128,10 @@ func initBeaconClient(nodeType, nodeAddr, nodeCert string) beacon.Client {
 		}).DialContext,
 		TLSHandshakeTimeout: 15 * time.Second,
 	}
-	var httpClient = &http.Client{
-		Timeout:   time.Second * 10,
+	// calls are bounded by their context instead of a client timeout, which would also cut streams
+	return &http.Client{
 		Transport: netTransport,
-	}
-
-	switch nodeType {
-	case "lighthouse":
-		return lighthouse.New(httpClient, nodeAddr)
-	case "teku":
-		return teku.New(httpClient, nodeAddr)
-	case "nimbus":
-		return nimbus.New(httpClient, nodeAddr)
-	case "v1":
-		return v1.New(httpClient, nodeAddr)
-	default:
-		log.Fatalf("node type not recognized: %s", nodeType)
-		return nil
-	}
+	}, nil
 }
 
 func IsURL(str string) bool {
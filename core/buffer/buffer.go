@@ -0,0 +1,266 @@
+package buffer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	proto "github.com/alethio/eth2stats-proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"github.com/alethio/eth2stats-client/types"
+)
+
+var log = logrus.WithField("module", "buffer")
+
+type Kind string
+
+const (
+	KindChainHead    Kind = "chainHead"
+	KindPeers        Kind = "peers"
+	KindAttestations Kind = "attestations"
+	KindSyncing      Kind = "syncing"
+	KindMemoryUsage  Kind = "memoryUsage"
+)
+
+const (
+	ReplayOrdered = "ordered"
+	ReplayLatest  = "latest"
+)
+
+// Entry is a single value that could not be delivered to the eth2stats server.
+type Entry struct {
+	Kind      Kind             `json:"kind"`
+	Time      time.Time        `json:"time"`
+	ChainHead *types.ChainHead `json:"chainHead,omitempty"`
+	Int       *int64           `json:"int,omitempty"`
+	Bool      *bool            `json:"bool,omitempty"`
+}
+
+type Config struct {
+	Path       string
+	MaxEntries int
+	MaxAge     time.Duration
+	// Replay is either ReplayOrdered or ReplayLatest.
+	Replay string
+}
+
+// Queue is a bounded queue of entries, persisted as JSON lines so it survives restarts.
+type Queue struct {
+	config Config
+
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func Open(config Config) (*Queue, error) {
+	if config.Replay != ReplayOrdered && config.Replay != ReplayLatest {
+		return nil, fmt.Errorf("unknown replay mode: %s", config.Replay)
+	}
+
+	q := &Queue{
+		config: config,
+	}
+
+	f, err := os.Open(config.Path)
+	if os.IsNotExist(err) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a partially written line is the worst that can happen on a crash
+			log.Warnf("skipping unreadable buffer entry: %s", err)
+			continue
+		}
+		q.entries = append(q.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.trim() {
+		if err := q.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+	if len(q.entries) > 0 {
+		log.Infof("found %d buffered entries", len(q.entries))
+	}
+	return q, nil
+}
+
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.entries)
+}
+
+// Push adds an entry, dropping the oldest ones once the queue is full.
+func (q *Queue) Push(e Entry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	q.entries = append(q.entries, e)
+	if q.trim() {
+		return q.rewrite()
+	}
+	return q.append(e)
+}
+
+// Replay hands the buffered entries to send in order, coalesced to the latest entry per kind
+// if configured so. Entries are removed once sent; the first failure stops the replay.
+func (q *Queue) Replay(send func(Entry) error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.trim()
+	pending := q.entries
+	if q.config.Replay == ReplayLatest {
+		pending = coalesce(q.entries)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	log.Infof("replaying %d buffered entries", len(pending))
+	for i, e := range pending {
+		if err := send(e); err != nil {
+			// keep what wasn't sent yet
+			q.entries = append([]Entry(nil), pending[i:]...)
+			if rewriteErr := q.rewrite(); rewriteErr != nil {
+				log.Errorf("persisting buffer: %s", rewriteErr)
+			}
+			return err
+		}
+	}
+
+	q.entries = nil
+	return q.rewrite()
+}
+
+// trim drops expired entries and the oldest entries above the size limit.
+// It returns whether anything was dropped.
+func (q *Queue) trim() bool {
+	dropped := 0
+	if q.config.MaxAge > 0 {
+		cutoff := time.Now().Add(-q.config.MaxAge)
+		for dropped < len(q.entries) && q.entries[dropped].Time.Before(cutoff) {
+			dropped++
+		}
+	}
+	if q.config.MaxEntries > 0 && len(q.entries)-dropped > q.config.MaxEntries {
+		dropped = len(q.entries) - q.config.MaxEntries
+	}
+	if dropped == 0 {
+		return false
+	}
+
+	log.Debugf("dropping %d buffered entries", dropped)
+	q.entries = append([]Entry(nil), q.entries[dropped:]...)
+	return true
+}
+
+func (q *Queue) append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_ = os.MkdirAll(filepath.Dir(q.config.Path), os.ModePerm)
+	f, err := os.OpenFile(q.config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (q *Queue) rewrite() error {
+	if len(q.entries) == 0 {
+		err := os.Remove(q.config.Path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var data []byte
+	for _, e := range q.entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	// replace the file atomically, a crash should not lose the whole buffer
+	tmp := q.config.Path + ".tmp"
+	_ = os.MkdirAll(filepath.Dir(q.config.Path), os.ModePerm)
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.config.Path)
+}
+
+func coalesce(entries []Entry) []Entry {
+	latest := make(map[Kind]Entry)
+	for _, e := range entries {
+		latest[e.Kind] = e
+	}
+	coalesced := make([]Entry, 0, len(latest))
+	for _, e := range latest {
+		coalesced = append(coalesced, e)
+	}
+	sort.Slice(coalesced, func(i, j int) bool {
+		return coalesced[i].Time.Before(coalesced[j].Time)
+	})
+	return coalesced
+}
+
+// TelemetryRecorder implements the telemetry service by pushing every value into the queue.
+type TelemetryRecorder struct {
+	Queue *Queue
+}
+
+// Check interface
+var _ = proto.TelemetryClient((*TelemetryRecorder)(nil))
+
+func (r *TelemetryRecorder) Peers(ctx context.Context, in *proto.PeersRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	peers := in.Peers
+	return &proto.DefaultResponse{}, r.Queue.Push(Entry{Kind: KindPeers, Int: &peers})
+}
+
+func (r *TelemetryRecorder) Attestations(ctx context.Context, in *proto.AttestationsRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	attestations := in.AttestationsInPool
+	return &proto.DefaultResponse{}, r.Queue.Push(Entry{Kind: KindAttestations, Int: &attestations})
+}
+
+func (r *TelemetryRecorder) Syncing(ctx context.Context, in *proto.SyncingRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	syncing := in.Syncing
+	return &proto.DefaultResponse{}, r.Queue.Push(Entry{Kind: KindSyncing, Bool: &syncing})
+}
+
+func (r *TelemetryRecorder) MemoryUsage(ctx context.Context, in *proto.MemoryUsageRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	memoryUsage := in.MemoryUsage
+	return &proto.DefaultResponse{}, r.Queue.Push(Entry{Kind: KindMemoryUsage, Int: &memoryUsage})
+}
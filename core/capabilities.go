@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/alethio/eth2stats-client/beacon"
+)
+
+// probeCapabilities finds out once which optional calls the beacon node supports,
+// so they don't have to be tried (and fail) over and over again. If the node can't tell,
+// none of the optional calls are made until the next connect probes again.
+func (c *Core) probeCapabilities(ctx context.Context) {
+	c.log.Info("probing beacon client capabilities")
+	callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+	capabilities, err := c.beaconClient.Capabilities(callCtx)
+	cancel()
+	if err != nil {
+		c.log.Warnf("probing beacon client capabilities: %s; assuming none", err)
+		capabilities = &beacon.Capabilities{}
+	}
+
+	fields := logrus.Fields{}
+	for name, supported := range capabilities.Matrix() {
+		fields[name] = supported
+	}
+	c.log.WithFields(fields).Info("beacon client capabilities")
+
+	c.statusMu.Lock()
+	c.capabilities = capabilities
+	c.statusMu.Unlock()
+}
+
+// knownCapabilities returns the probed capabilities, or none if the node was never probed.
+func (c *Core) knownCapabilities() beacon.Capabilities {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	if c.capabilities == nil {
+		return beacon.Capabilities{}
+	}
+	return *c.capabilities
+}
+
+// contextWithCapabilities tells the eth2stats server which optional data it can expect,
+// as a comma separated list of the supported capabilities.
+func (c *Core) contextWithCapabilities(ctx context.Context) context.Context {
+	var supported []string
+	for name, ok := range c.knownCapabilities().Matrix() {
+		if ok {
+			supported = append(supported, name)
+		}
+	}
+	sort.Strings(supported)
+
+	return metadata.AppendToOutgoingContext(ctx, "capabilities", strings.Join(supported, ","))
+}
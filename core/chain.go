@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/clock"
+)
+
+// waitForGenesis blocks until the chain has started, logging a countdown in the meantime.
+// It returns false if the context was cancelled first.
+func (c *Core) waitForGenesis(ctx context.Context) bool {
+	genesis, ok := c.clock.Genesis()
+	if !ok {
+		return true
+	}
+	for {
+		left := time.Until(genesis)
+		if left <= 0 {
+			return true
+		}
+		c.log.WithField("genesisTime", genesis.Unix()).Infof("waiting for genesis in %s", left.Round(time.Second))
+
+		// log less often the further away genesis is
+		step := 10 * time.Second
+		if left > time.Hour {
+			step = time.Hour
+		} else if left > time.Minute {
+			step = time.Minute
+		}
+		if step > left {
+			step = left
+		}
+		if !clock.SleepUntil(ctx, time.Now().Add(step)) {
+			return false
+		}
+	}
+}
+
+// watchEpochs checks the finality checkpoints at every epoch boundary. Chain heads are rate
+// limited, so a checkpoint change could otherwise go unreported until the next head is sent.
+func (c *Core) watchEpochs(ctx context.Context) error {
+	for {
+		next, ok := c.clock.NextEpochTick(time.Now())
+		if !ok {
+			// without a clock there are no epochs to watch
+			<-ctx.Done()
+			return nil
+		}
+		if !clock.SleepUntil(ctx, next) {
+			return nil
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+		head, err := c.beaconClient.GetChainHead(callCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			c.log.Errorf("checking finality at epoch boundary: %s", err)
+			continue
+		}
+
+		c.statusMu.Lock()
+		last := c.lastHead
+		c.statusMu.Unlock()
+		if last != nil &&
+			last.FinalizedBlockRoot == head.FinalizedBlockRoot &&
+			last.JustifiedBlockRoot == head.JustifiedBlockRoot {
+			continue
+		}
+
+		c.log.WithField("finalizedSlot", head.FinalizedSlot).Debug("finality checkpoints changed")
+		c.recordCheckpoints(*head)
+		_, err = c.statsService.ChainHead(c.contextWithToken(ctx), chainHeadRequest(*head))
+		if err != nil {
+			c.bufferHead(*head)
+			return &ServerError{Op: "sending chain head", Err: err}
+		}
+		c.metrics.ChainHeadsSent.Inc()
+	}
+}
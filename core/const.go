@@ -5,5 +5,17 @@ import (
 )
 
 const (
-	HeartbeatInterval = 12 * time.Second
+	// Defaults of the configurable intervals and limits.
+	HeartbeatInterval  = 12 * time.Second
+	ChainHeadRateLimit = 1
+	ChainHeadBurst     = 1
+
+	// Defaults of the reconnect backoff when reporting to several servers.
+	ServerRetryInterval    = 5 * time.Second
+	MaxServerRetryInterval = 5 * time.Minute
+	// Bounds a single call to one of several servers, so a stalled one doesn't hold up the others.
+	ServerCallTimeout = 10 * time.Second
+
+	// Buffered entries per second sent to the eth2stats server when replaying.
+	ReplayRateLimit = 10
 )
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	proto "github.com/alethio/eth2stats-proto"
@@ -11,7 +12,12 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/failover"
+	"github.com/alethio/eth2stats-client/clock"
+	"github.com/alethio/eth2stats-client/core/buffer"
 	"github.com/alethio/eth2stats-client/core/telemetry"
+	"github.com/alethio/eth2stats-client/exporter"
+	"github.com/alethio/eth2stats-client/types"
 	metricsWatcher "github.com/alethio/eth2stats-client/watcher/metrics"
 )
 
@@ -21,182 +27,438 @@ type Eth2statsConfig struct {
 	Version    string
 	ServerAddr string
 	TLS        bool
-	NodeName   string
+	// TLSConfig applies to ServerAddr when TLS is enabled.
+	TLSConfig TLSConfig
+	NodeName  string
+	// Sink is where to send the data: sink.Server, sink.Stdout or sink.FilePrefix followed by a path.
+	Sink string
+
+	HeartbeatInterval time.Duration
+	// ChainHeadRateLimit is how many chain heads may be sent per second, in bursts of up to ChainHeadBurst.
+	ChainHeadRateLimit float64
+	ChainHeadBurst     int
+
+	// Servers replaces ServerAddr and TLS to report to several eth2stats servers at once.
+	Servers []ServerConfig
+	// RetryInterval is how long to wait before reconnecting to one of several servers, doubling up to MaxRetryInterval.
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
 }
 
 type BeaconNodeConfig struct {
-	Type        string
-	Addr        string
+	Type string
+	// Addrs are redundant endpoints of the same type, in order of preference.
+	Addrs       []string
 	TLSCert     string
 	MetricsAddr string
+
+	MetricsPollInterval time.Duration
+	MetricsTimeout      time.Duration
+	// MetricsDialTimeout applies to both connecting and the TLS handshake.
+	MetricsDialTimeout   time.Duration
+	MetricsRetryAttempts uint
+}
+
+type BufferConfig struct {
+	Enabled    bool
+	MaxEntries int
+	MaxAge     time.Duration
+	// Replay is either buffer.ReplayOrdered or buffer.ReplayLatest.
+	Replay string
+}
+
+type ChainConfig struct {
+	SecondsPerSlot uint64
+	SlotsPerEpoch  uint64
+	// PollOffset is how far into each slot the beacon node is polled.
+	PollOffset time.Duration
+	// HeadPollInterval is how often the chain head is polled while it lags behind the current slot.
+	HeadPollInterval time.Duration
+	// FinalityStalledEpochs and HeadLaggingSlots are the thresholds of the finality monitor.
+	FinalityStalledEpochs uint64
+	HeadLaggingSlots      uint64
+}
+
+type TelemetryConfig struct {
+	// PollingInterval is used until the genesis time and slot timing are known.
+	PollingInterval time.Duration
+	// MemoryUsageThreshold is the change in bytes from which memory usage is sent again.
+	MemoryUsageThreshold int64
+	// ChurnWindow is what peer churn rates are averaged over; peers connected for at least LongLivedPeerAge count as long-lived.
+	ChurnWindow      time.Duration
+	LongLivedPeerAge time.Duration
 }
 
 type Config struct {
 	Eth2stats  Eth2statsConfig
 	BeaconNode BeaconNodeConfig
+	Chain      ChainConfig
+	Telemetry  TelemetryConfig
+	Buffer     BufferConfig
 	DataFolder string
+	// TokenFile is resolved relative to DataFolder; defaults to TokenFile.
+	TokenFile string
+	// Validators are the indices or 0x-prefixed pubkeys of the validators to watch.
+	Validators []string
+	// History is kept across reconnects; a new one is started if it is nil.
+	History *History
 }
 
 type Core struct {
-	config Config
-	token  string
+	config  Config
+	token   string
+	log     *logrus.Entry
+	metrics *exporter.NodeMetrics
 
 	statsService     proto.Eth2StatsClient
 	telemetryService proto.TelemetryClient
 
+	clock          *clock.Clock
 	beaconClient   beacon.Client
+	capabilities   *beacon.Capabilities
 	metricsWatcher *metricsWatcher.Watcher
+	telemetry      *telemetry.Telemetry
+	buffer         *buffer.Queue
+	servers        *serverSet
+	// conns to the eth2stats servers, closed with the core
+	conns []*grpc.ClientConn
+
+	statusMu      sync.Mutex
+	connected     bool
+	lastHeartbeat time.Time
+	lastHead      *types.ChainHead
+	lastErr       error
+	beaconErr     error
 }
 
-func New(config Config) *Core {
+func New(config Config) (*Core, error) {
+	// catch mistakes before setting anything up
+	if problems := config.Problems(); len(problems) > 0 {
+		return nil, problems[0]
+	}
+
 	c := Core{
-		config:       config,
-		beaconClient: initBeaconClient(config.BeaconNode.Type, config.BeaconNode.Addr, config.BeaconNode.TLSCert),
+		config:  config,
+		log:     log.WithField("node", config.Eth2stats.NodeName),
+		metrics: exporter.ForNode(config.Eth2stats.NodeName),
+		clock:   newClock(config.Chain),
+	}
+	if c.config.History == nil {
+		c.config.History = NewHistory(config)
 	}
 
-	c.initEth2statsClient()
+	// the node type is detected when connecting, as that needs the node to be up
+	if config.BeaconNode.Type != AutoDetect {
+		err := c.setUpBeaconClient(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("setting up beacon client: %s", err)
+		}
+	}
+
+	err := c.initEth2statsClient()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
 
 	if config.BeaconNode.MetricsAddr != "" {
 		c.metricsWatcher = metricsWatcher.New(metricsWatcher.Config{
-			MetricsURL: config.BeaconNode.MetricsAddr,
+			MetricsURL:    config.BeaconNode.MetricsAddr,
+			PollInterval:  config.BeaconNode.MetricsPollInterval,
+			PollTimeout:   config.BeaconNode.MetricsTimeout,
+			DialTimeout:   config.BeaconNode.MetricsDialTimeout,
+			RetryAttempts: config.BeaconNode.MetricsRetryAttempts,
 		})
 	}
 
-	err := c.searchToken()
-	if err != nil {
-		log.Fatalf("loading auth token", err)
+	// with several servers every server keeps its own token
+	if c.servers == nil {
+		err = c.searchToken()
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("loading auth token: %s", err)
+		}
 	}
 
-	return &c
+	if config.Buffer.Enabled {
+		c.buffer, err = buffer.Open(buffer.Config{
+			Path:       c.bufferPath(),
+			MaxEntries: config.Buffer.MaxEntries,
+			MaxAge:     config.Buffer.MaxAge,
+			Replay:     config.Buffer.Replay,
+		})
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("opening buffer: %s", err)
+		}
+	}
+
+	return &c, nil
+}
+
+// Close closes the connections to the eth2stats servers; the core can't report after it.
+func (c *Core) Close() {
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil {
+			c.log.Debugf("closing eth2stats server connection: %s", err)
+		}
+	}
+	c.conns = nil
 }
 
-func (c *Core) connectToServer() error {
-	log.Info("getting beacon client version")
-	version, err := c.beaconClient.GetVersion()
+func (c *Core) connectToServer(ctx context.Context) error {
+	if c.beaconClient == nil {
+		err := c.setUpBeaconClient(ctx)
+		if err != nil {
+			return &BeaconError{Op: "detecting node type", Err: err}
+		}
+	}
+
+	c.log.Info("getting beacon client version")
+	callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+	version, err := c.beaconClient.GetVersion(callCtx)
+	cancel()
 	if err != nil {
-		return err
+		return &BeaconError{Op: "getting version", Err: err}
 	}
 
-	log.WithField("version", version).Info("got beacon client version")
+	c.log.WithField("version", version).Info("got beacon client version")
 
-	log.Info("getting beacon client genesis time")
-	genesisTime, err := c.beaconClient.GetGenesisTime()
+	c.log.Info("getting beacon client genesis time")
+	callCtx, cancel = context.WithTimeout(ctx, beacon.CallTimeout)
+	genesisTime, err := c.beaconClient.GetGenesisTime(callCtx)
+	cancel()
 	if err != nil {
-		return err
+		return &BeaconError{Op: "getting genesis time", Err: err}
 	}
 
-	log.WithField("genesisTime", genesisTime).Info("beacon client genesis time")
+	c.log.WithField("genesisTime", genesisTime).Info("beacon client genesis time")
+	c.clock.SetGenesis(genesisTime)
+
+	c.probeCapabilities(ctx)
 
-	log.Info("awaiting connection to eth2stats server")
-	resp, err := c.statsService.Connect(c.contextWithToken(), &proto.ConnectRequest{
+	if !c.capabilities.Spec {
+		c.log.Info("beacon client does not serve its spec; using the configured chain timing")
+	} else {
+		c.log.Info("getting beacon client spec")
+		callCtx, cancel = context.WithTimeout(ctx, beacon.CallTimeout)
+		spec, err := c.beaconClient.GetSpec(callCtx)
+		cancel()
+		if err != nil {
+			c.log.Warnf("getting beacon client spec: %s; using the configured chain timing", err)
+		} else {
+			c.log.WithFields(logrus.Fields{
+				"configName":     spec.ConfigName,
+				"secondsPerSlot": spec.SecondsPerSlot,
+				"slotsPerEpoch":  spec.SlotsPerEpoch,
+			}).Info("got beacon client spec")
+			c.clock.SetSpec(spec.SecondsPerSlot, spec.SlotsPerEpoch)
+		}
+	}
+
+	c.log.Info("awaiting connection to eth2stats server")
+	resp, err := c.statsService.Connect(c.contextWithCapabilities(c.contextWithToken(ctx)), &proto.ConnectRequest{
 		Name:             c.config.Eth2stats.NodeName,
 		Version:          version,
 		GenesisTime:      genesisTime,
 		Eth2StatsVersion: c.config.Eth2stats.Version,
 	}, grpc.WaitForReady(true))
 	if err != nil {
-		return fmt.Errorf("eth2stats: failed to connect: %s", err)
+		return &ServerError{Op: "connecting", Err: err}
 	}
 
-	c.updateToken(resp.Token)
+	err = c.updateToken(resp.Token)
+	if err != nil {
+		return err
+	}
 
-	log.Info("getting chain head for initial feed")
-	head, err := c.beaconClient.GetChainHead()
+	err = c.replayBuffer(ctx)
 	if err != nil {
 		return err
 	}
-	log.WithField("headSlot", head.HeadSlot).Info("got chain head")
 
-	_, err = c.statsService.ChainHead(c.contextWithToken(), &proto.ChainHeadRequest{
-		HeadSlot:           head.HeadSlot,
-		HeadBlockRoot:      head.HeadBlockRoot,
-		FinalizedSlot:      head.FinalizedSlot,
-		FinalizedBlockRoot: head.FinalizedBlockRoot,
-		JustifiedSlot:      head.JustifiedSlot,
-		JustifiedBlockRoot: head.JustifiedBlockRoot,
-	})
+	if genesis, _ := c.clock.Genesis(); time.Now().Before(genesis) {
+		// there is no chain to report yet
+		c.log.Info("successfully connected to eth2stats server")
+		return nil
+	}
+
+	c.log.Info("getting chain head for initial feed")
+	callCtx, cancel = context.WithTimeout(ctx, beacon.CallTimeout)
+	head, err := c.beaconClient.GetChainHead(callCtx)
+	cancel()
+	if err != nil {
+		return &BeaconError{Op: "getting chain head", Err: err}
+	}
+	c.log.WithField("headSlot", head.HeadSlot).Info("got chain head")
+	c.recordHead(ctx, *head)
+
+	_, err = c.statsService.ChainHead(c.contextWithToken(ctx), chainHeadRequest(*head))
 	if err != nil {
-		log.Fatalf("sending chain head: %s", err)
+		return &ServerError{Op: "sending chain head", Err: err}
 	}
 
-	log.Info("successfully connected to eth2stats server")
+	c.log.Info("successfully connected to eth2stats server")
 	return nil
 }
 
-func (c *Core) watchNewHeads(ctx context.Context) {
+func (c *Core) watchNewHeads(ctx context.Context) error {
 	for {
-		log.Info("setting up chain heads subscription")
-		sub, err := c.beaconClient.SubscribeChainHeads()
+		c.log.Info("setting up chain heads subscription")
+		sub, err := c.beaconClient.SubscribeChainHeads(ctx)
 		if err != nil {
-			// TODO handle gracefully
-			log.Fatal(err)
+			return &BeaconError{Op: "subscribing to chain heads", Err: err}
 		}
+		subDone := make(chan struct{})
 		go func() {
-			<-ctx.Done()
+			select {
+			case <-ctx.Done():
+			case <-subDone:
+			}
 			sub.Close()
 		}()
 
-		limiter := rate.NewLimiter(1, 1)
+		limiter := rate.NewLimiter(rate.Limit(c.config.Eth2stats.ChainHeadRateLimit), c.config.Eth2stats.ChainHeadBurst)
 
 		for msg := range sub.Channel() {
+			c.recordHead(ctx, msg)
 			if limiter.Allow() {
-				_, err := c.statsService.ChainHead(c.contextWithToken(), &proto.ChainHeadRequest{
-					HeadSlot:           msg.HeadSlot,
-					HeadBlockRoot:      msg.HeadBlockRoot,
-					FinalizedSlot:      msg.FinalizedSlot,
-					FinalizedBlockRoot: msg.FinalizedBlockRoot,
-					JustifiedSlot:      msg.JustifiedSlot,
-					JustifiedBlockRoot: msg.JustifiedBlockRoot,
-				})
+				_, err := c.statsService.ChainHead(c.contextWithToken(ctx), chainHeadRequest(msg))
 				if err != nil {
-					log.Fatalf("sending chain head: %s", err)
+					c.bufferHead(msg)
+					close(subDone)
+					return &ServerError{Op: "sending chain head", Err: err}
 				}
+				c.metrics.ChainHeadsSent.Inc()
 			} else {
-				log.Debug("ChainHead request was skipped due to rate limiting")
+				c.log.Debug("ChainHead request was skipped due to rate limiting")
+				c.metrics.ChainHeadsRateLimited.Inc()
 			}
 		}
+		close(subDone)
 
-		log.Warn("chain heads subscription closed")
+		// Check if the service needs to stop yet.
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		c.log.Warn("chain heads subscription closed")
 	}
 }
 
-func (c *Core) sendHeartbeat(ctx context.Context) {
-	ticker := time.NewTicker(HeartbeatInterval)
+func (c *Core) sendHeartbeat(ctx context.Context) error {
+	ticker := time.NewTicker(c.config.Eth2stats.HeartbeatInterval)
 	for {
 		select {
 		case <-ticker.C:
-			log.Trace("sending heartbeat")
+			c.log.Trace("sending heartbeat")
 
-			_, err := c.statsService.Heartbeat(c.contextWithToken(), &proto.HeartbeatRequest{})
+			_, err := c.statsService.Heartbeat(c.contextWithToken(ctx), &proto.HeartbeatRequest{})
 			if err != nil {
-				log.Fatalf("sending heartbeat: %s", err)
-
-				continue
+				c.metrics.HeartbeatFailures.Inc()
+				ticker.Stop()
+				return &ServerError{Op: "sending heartbeat", Err: err}
 			}
-			log.Trace("done sending heartbeat")
+			c.metrics.HeartbeatsSent.Inc()
+			c.recordHeartbeat()
+			c.log.Trace("done sending heartbeat")
 		case <-ctx.Done():
 			ticker.Stop()
-			return
+			return nil
 		}
 	}
 }
 
+// Run reports to the eth2stats server until the context is cancelled or one of the
+// reporting routines fails, in which case the others are stopped and the error is returned.
 func (c *Core) Run(ctx context.Context) error {
-	err := c.connectToServer()
+	err := c.connectToServer(ctx)
 	if err != nil {
+		c.setConnected(false, err)
 		return fmt.Errorf("setting up: %s", err)
 	}
+	c.setConnected(true, nil)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	if c.metricsWatcher != nil {
 		go c.metricsWatcher.Run(ctx)
 	}
+	if f, ok := c.beaconClient.(*failover.Client); ok {
+		go f.Run(ctx)
+	}
+	if c.servers != nil {
+		go c.servers.Run(ctx)
+	}
 
-	go c.watchNewHeads(ctx)
+	t := telemetry.New(c.telemetryService, c.beaconClient, c.metricsWatcher, c.contextWithToken, c.metrics, c.clock, c.knownCapabilities(), c.telemetryConfig())
+	c.statusMu.Lock()
+	c.telemetry = t
+	c.statusMu.Unlock()
 
-	t := telemetry.New(c.telemetryService, c.beaconClient, c.metricsWatcher, c.contextWithToken)
-	go t.Run(ctx)
+	// heartbeats keep the connection alive while waiting for genesis, everything else waits
+	genesis := make(chan struct{})
+	go func() {
+		if c.waitForGenesis(ctx) {
+			close(genesis)
+		}
+	}()
+	afterGenesis := func(routine func(context.Context) error) func(context.Context) error {
+		return func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-genesis:
+			}
+			return routine(ctx)
+		}
+	}
 
-	// block while sending heartbeat
-	c.sendHeartbeat(ctx)
-	return nil
+	routines := []func(context.Context) error{
+		afterGenesis(c.watchNewHeads),
+		afterGenesis(t.Run),
+		afterGenesis(c.watchEpochs),
+		afterGenesis(c.watchFinality),
+		c.sendHeartbeat,
+	}
+	errs := make(chan error, len(routines))
+	for _, routine := range routines {
+		go func(routine func(context.Context) error) {
+			errs <- routine(ctx)
+		}(routine)
+	}
+
+	// the first failure stops the other routines
+	var runErr error
+	for range routines {
+		err := <-errs
+		// errors caused by shutting down don't count
+		if err != nil && runErr == nil && ctx.Err() == nil {
+			runErr = err
+			cancel()
+		}
+	}
+	c.setConnected(false, runErr)
+	return runErr
+}
+
+func (c *Core) telemetryConfig() telemetry.Config {
+	return telemetry.Config{
+		PollingInterval:      c.config.Telemetry.PollingInterval,
+		MemoryUsageThreshold: c.config.Telemetry.MemoryUsageThreshold,
+		Validators:           c.config.Validators,
+		Churn:                c.config.History.peerChurn,
+	}
+}
+
+func newClock(config ChainConfig) *clock.Clock {
+	return clock.New(clock.Config{
+		SecondsPerSlot: config.SecondsPerSlot,
+		SlotsPerEpoch:  config.SlotsPerEpoch,
+		Offset:         config.PollOffset,
+		PollInterval:   config.HeadPollInterval,
+	})
 }
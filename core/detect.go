@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/lighthouse"
+	"github.com/alethio/eth2stats-client/beacon/nimbus"
+	"github.com/alethio/eth2stats-client/beacon/prysm"
+	"github.com/alethio/eth2stats-client/beacon/teku"
+	"github.com/alethio/eth2stats-client/beacon/v1"
+)
+
+// AutoDetect is the node type to have the client find out which API the beacon node serves.
+const AutoDetect = "auto"
+
+// ErrNoKnownAPI is returned when none of the supported APIs answered at the node address.
+var ErrNoKnownAPI = errors.New("no supported beacon node API found")
+
+// detectBeaconClient probes the node address for the supported APIs and sets up the richest one.
+// gRPC addresses can only be prysm; URLs are tried with the standard API first, then the legacy
+// APIs of teku, lighthouse and nimbus.
+func (c *Core) detectBeaconClient(ctx context.Context, addr string) (beacon.Client, error) {
+	cert := c.config.BeaconNode.TLSCert
+	c.log.WithField("addr", addr).Info("detecting beacon node type")
+
+	type candidate struct {
+		nodeType string
+		reason   string
+		client   beacon.Client
+		// match tells from the version the node reports whether it is this type
+		match func(version string) bool
+	}
+	var candidates []candidate
+
+	if !IsURL(addr) {
+		client, err := prysm.New(prysm.Config{GRPCAddr: addr, TLSCert: cert})
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate{
+			nodeType: "prysm",
+			reason:   "the address is not a URL and the prysm node service responded",
+			client:   client,
+		})
+	} else {
+		httpClient, err := newHTTPClient(addr, cert)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates,
+			candidate{
+				nodeType: "v1",
+				reason:   "the node serves the standard API, which supports the most features",
+				client:   v1.New(httpClient, addr, c.clock),
+			},
+			candidate{
+				nodeType: "teku",
+				reason:   "the node serves the legacy teku API",
+				client:   teku.New(httpClient, addr, c.clock),
+				match: func(version string) bool {
+					return strings.HasPrefix(strings.ToLower(version), "teku")
+				},
+			},
+			candidate{
+				nodeType: "lighthouse",
+				reason:   "the node serves the legacy lighthouse API",
+				client:   lighthouse.New(httpClient, addr, c.clock),
+			},
+			candidate{
+				nodeType: "nimbus",
+				reason:   "the node serves the nimbus JSON-RPC API",
+				client:   nimbus.New(httpClient, addr, c.clock),
+			},
+		)
+	}
+
+	var errs []string
+	for _, candidate := range candidates {
+		callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+		version, err := candidate.client.GetVersion(callCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", candidate.nodeType, err))
+			continue
+		}
+		// legacy routes answer with an empty body on other nodes
+		if version == "" || (candidate.match != nil && !candidate.match(version)) {
+			continue
+		}
+
+		c.log.WithFields(logrus.Fields{
+			"addr":    addr,
+			"version": version,
+		}).Infof("detected %s beacon node: %s", candidate.nodeType, candidate.reason)
+		return candidate.client, nil
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s (%s)", ErrNoKnownAPI, strings.Join(errs, "; "))
+	}
+	return nil, ErrNoKnownAPI
+}
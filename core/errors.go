@@ -0,0 +1,33 @@
+package core
+
+import (
+	"fmt"
+)
+
+// ServerError is returned when a call to the eth2stats server fails.
+type ServerError struct {
+	Op  string
+	Err error
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("eth2stats: %s: %s", e.Op, e.Err)
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// BeaconError is returned when the beacon node can't provide data the client depends on.
+type BeaconError struct {
+	Op  string
+	Err error
+}
+
+func (e *BeaconError) Error() string {
+	return fmt.Sprintf("beacon: %s: %s", e.Op, e.Err)
+}
+
+func (e *BeaconError) Unwrap() error {
+	return e.Err
+}
@@ -2,33 +2,73 @@ package core
 
 import (
 	"crypto/tls"
+	"fmt"
 
 	proto "github.com/alethio/eth2stats-proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+
+	"github.com/alethio/eth2stats-client/core/sink"
 )
 
-func (c *Core) initEth2statsClient() {
-	log.Info("setting up eth2stats server connection")
+func (c *Core) initEth2statsClient() error {
+	if c.config.Eth2stats.Sink != sink.Server {
+		s, err := sink.Open(c.config.Eth2stats.Sink, c.config.Eth2stats.NodeName)
+		if err != nil {
+			return fmt.Errorf("opening sink: %s", err)
+		}
+		c.log.Infof("writing data to %s instead of the eth2stats server", c.config.Eth2stats.Sink)
+		c.statsService = s
+		c.telemetryService = s
+		return nil
+	}
+
+	if len(c.config.Eth2stats.Servers) > 0 {
+		c.log.Infof("setting up connections to %d eth2stats servers", len(c.config.Eth2stats.Servers))
+		servers, err := c.newServerSet()
+		if err != nil {
+			return err
+		}
+		c.servers = servers
+		c.statsService = servers
+		c.telemetryService = servers
+		return nil
+	}
+
+	c.log.Info("setting up eth2stats server connection")
+
+	conn, err := dialServer(c.config.Eth2stats.ServerAddr, c.config.Eth2stats.TLS, c.config.Eth2stats.TLSConfig)
+	if err != nil {
+		return err
+	}
+	c.conns = append(c.conns, conn)
 
+	c.statsService = proto.NewEth2StatsClient(conn)
+	c.telemetryService = proto.NewTelemetryClient(conn)
+	return nil
+}
+
+func dialServer(addr string, useTLS bool, config TLSConfig) (*grpc.ClientConn, error) {
 	var conn *grpc.ClientConn
 	var err error
 
-	if c.config.Eth2stats.TLS {
-		tlsConfig := &tls.Config{}
+	if useTLS {
+		var tlsConfig *tls.Config
+		tlsConfig, err = NewTLSConfig(addr, config)
+		if err != nil {
+			return nil, fmt.Errorf("setting up TLS for %s: %s", addr, err)
+		}
 		conn, err = grpc.Dial(
-			c.config.Eth2stats.ServerAddr,
+			addr,
 			grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
 		)
 	} else {
-		conn, err = grpc.Dial(c.config.Eth2stats.ServerAddr,
+		conn, err = grpc.Dial(addr,
 			grpc.WithInsecure(),
 		)
 	}
 	if err != nil {
-		log.Fatalf("failed to connect to eth2stats: %v", err)
+		return nil, fmt.Errorf("failed to connect to eth2stats: %v", err)
 	}
-
-	c.statsService = proto.NewEth2StatsClient(conn)
-	c.telemetryService = proto.NewTelemetryClient(conn)
+	return conn, nil
 }
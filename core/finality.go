@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/alethio/eth2stats-client/clock"
+	"github.com/alethio/eth2stats-client/core/finality"
+	"github.com/alethio/eth2stats-client/core/sink"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+func (c *Core) finalityConfig() finality.Config {
+	return finality.Config{
+		StalledEpochs: c.config.Chain.FinalityStalledEpochs,
+		LaggingSlots:  c.config.Chain.HeadLaggingSlots,
+	}
+}
+
+// watchFinality evaluates the finality of the last head at every slot, as a stalled head
+// brings no new heads to evaluate it on. Changes of the conditions are logged right away,
+// the values are reported once per epoch.
+func (c *Core) watchFinality(ctx context.Context) error {
+	var last *types.Finality
+	for {
+		next, ok := c.clock.NextSlotTick(time.Now())
+		if !ok {
+			// without a clock there are no slots to watch
+			<-ctx.Done()
+			return nil
+		}
+		if !clock.SleepUntil(ctx, next) {
+			return nil
+		}
+
+		c.statusMu.Lock()
+		head := c.lastHead
+		c.statusMu.Unlock()
+		if head == nil {
+			continue
+		}
+		f, ok := finality.Evaluate(*head, c.clock, c.finalityConfig(), time.Now())
+		if !ok {
+			continue
+		}
+		c.metrics.SeenFinality(f)
+
+		changed := last == nil || last.FinalityStalled != f.FinalityStalled || last.HeadLagging != f.HeadLagging
+		newEpoch := last == nil || c.clock.EpochOf(last.CurrentSlot) != c.clock.EpochOf(f.CurrentSlot)
+		if changed {
+			c.logFinality(last, f)
+		}
+		if changed || newEpoch {
+			c.reportFinality(ctx, f)
+		}
+		last = &f
+	}
+}
+
+func (c *Core) logFinality(last *types.Finality, f types.Finality) {
+	log := c.log.WithFields(logrus.Fields{
+		"epochsSinceFinality": f.EpochsSinceFinality,
+		"headLagSlots":        f.HeadLagSlots,
+	})
+	wasStalled := last != nil && last.FinalityStalled
+	wasLagging := last != nil && last.HeadLagging
+	switch {
+	case f.FinalityStalled && !wasStalled:
+		log.Warnf("finality stalled: %d epochs since the finalized checkpoint", f.EpochsSinceFinality)
+	case !f.FinalityStalled && wasStalled:
+		log.Info("finality recovered")
+	}
+	switch {
+	case f.HeadLagging && !wasLagging:
+		log.Warnf("head lagging: %d slots behind the current slot", f.HeadLagSlots)
+	case !f.HeadLagging && wasLagging:
+		log.Info("head caught up")
+	}
+}
+
+func (c *Core) reportFinality(ctx context.Context, f types.Finality) {
+	recorder, ok := c.statsService.(sink.Recorder)
+	if !ok {
+		return
+	}
+	if err := recorder.Record(c.contextWithToken(ctx), "Finality", f); err != nil {
+		c.log.Errorf("reporting finality: %s", err)
+	}
+}
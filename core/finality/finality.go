@@ -0,0 +1,58 @@
+package finality
+
+import (
+	"time"
+
+	"github.com/alethio/eth2stats-client/clock"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+const (
+	// Defaults of the thresholds. Finality is normally 2 epochs behind the current one,
+	// and the head at most a slot behind unless blocks are missed.
+	StalledEpochs = 4
+	LaggingSlots  = 4
+)
+
+type Config struct {
+	// Finality is stalled once more than StalledEpochs epochs passed since the finalized checkpoint.
+	StalledEpochs uint64
+	// The head is lagging once it is more than LaggingSlots slots behind the current slot.
+	LaggingSlots uint64
+}
+
+// Evaluate derives the finality of the chain from a head at the given time. It returns false if the clock
+// does not know the genesis time or the epoch length yet, or the chain has not started.
+func Evaluate(head types.ChainHead, chainClock *clock.Clock, config Config, now time.Time) (types.Finality, bool) {
+	if config.StalledEpochs == 0 {
+		config.StalledEpochs = StalledEpochs
+	}
+	if config.LaggingSlots == 0 {
+		config.LaggingSlots = LaggingSlots
+	}
+
+	slot, started := chainClock.SlotAt(now)
+	if !started || chainClock.SlotsPerEpoch() == 0 {
+		return types.Finality{}, false
+	}
+	epoch := chainClock.EpochOf(slot)
+
+	f := types.Finality{
+		CurrentSlot:              slot,
+		EpochsSinceFinality:      since(epoch, chainClock.EpochOf(head.FinalizedSlot)),
+		EpochsSinceJustification: since(epoch, chainClock.EpochOf(head.JustifiedSlot)),
+		HeadLagSlots:             since(slot, head.HeadSlot),
+		HeadAge:                  now.Sub(chainClock.SlotStart(head.HeadSlot)).Seconds(),
+	}
+	f.FinalityStalled = f.EpochsSinceFinality > config.StalledEpochs
+	f.HeadLagging = f.HeadLagSlots > config.LaggingSlots
+	return f, true
+}
+
+// since is how far a is ahead of b, or 0 if it isn't, e.g. because the clocks of node and client differ.
+func since(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/alethio/eth2stats-client/core/reorg"
+	"github.com/alethio/eth2stats-client/core/telemetry"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// History is what is learned about a node over several connections. A new Core is set up for every
+// connection, so the caller keeps the history of a node and passes it on through the config.
+type History struct {
+	peerChurn *telemetry.ChurnTracker
+
+	mu         sync.Mutex
+	reorgs     *reorg.Tracker
+	reorgCount uint64
+	lastReorg  *types.Reorg
+}
+
+func NewHistory(config Config) *History {
+	return &History{
+		peerChurn: telemetry.NewChurnTracker(config.Telemetry.ChurnWindow, config.Telemetry.LongLivedPeerAge),
+		reorgs:    reorg.New(),
+	}
+}
+
+// observeHead tracks a new head and returns the reorg it caused, if any.
+func (h *History) observeHead(head types.ChainHead) *types.Reorg {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.reorgs.Observe(head)
+	if r != nil {
+		h.reorgCount++
+		h.lastReorg = r
+	}
+	return r
+}
+
+// reorgStatus returns how many reorgs were seen and a copy of the last one.
+func (h *History) reorgStatus() (uint64, *types.Reorg) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.lastReorg == nil {
+		return h.reorgCount, nil
+	}
+	last := *h.lastReorg
+	return h.reorgCount, &last
+}
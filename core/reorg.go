@@ -0,0 +1,31 @@
+package core
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/alethio/eth2stats-client/core/sink"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// reportReorg logs a reorg and records it if the eth2stats service keeps records.
+func (c *Core) reportReorg(ctx context.Context, reorg types.Reorg) {
+	c.log.WithFields(logrus.Fields{
+		"oldHeadSlot": reorg.OldHeadSlot,
+		"oldHeadRoot": reorg.OldHeadRoot,
+		"newHeadSlot": reorg.NewHeadSlot,
+		"newHeadRoot": reorg.NewHeadRoot,
+		"dropped":     reorg.Dropped,
+		"estimated":   reorg.Estimated,
+	}).Warnf("chain reorg of depth %d", reorg.Depth)
+	c.metrics.SeenReorg(reorg.Depth)
+
+	recorder, ok := c.statsService.(sink.Recorder)
+	if !ok {
+		return
+	}
+	if err := recorder.Record(c.contextWithToken(ctx), "Reorg", reorg); err != nil {
+		c.log.Errorf("reporting reorg: %s", err)
+	}
+}
@@ -0,0 +1,130 @@
+package reorg
+
+import (
+	"time"
+
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// Window is how many recent head blocks are kept to find the common ancestor of a reorg.
+const Window = 64
+
+type block struct {
+	root   string
+	slot   uint64
+	parent string
+}
+
+// Tracker detects reorgs from successive chain heads. It relies on the parent roots of the heads
+// to tell how far back a new head forks off; for nodes that don't report them, only reorgs to a
+// head that is not ahead of the previous one can be told apart from missed heads.
+// It is not safe for concurrent use.
+type Tracker struct {
+	blocks map[string]block
+	// roots in the order they were seen, to drop the oldest beyond the window
+	order []string
+	head  *block
+}
+
+func New() *Tracker {
+	return &Tracker{
+		blocks: make(map[string]block),
+	}
+}
+
+// Observe records a new head and returns the reorg it caused, if any.
+func (t *Tracker) Observe(head types.ChainHead) *types.Reorg {
+	if head.HeadBlockRoot == "" || (t.head != nil && t.head.root == head.HeadBlockRoot) {
+		// e.g. only the checkpoints changed
+		return nil
+	}
+
+	b := block{
+		root:   head.HeadBlockRoot,
+		slot:   head.HeadSlot,
+		parent: head.ParentRoot,
+	}
+	t.add(b)
+	old := t.head
+	t.head = &b
+	if old == nil || b.parent == old.root {
+		return nil
+	}
+
+	// everything the new head descends from, as far as it was seen
+	ancestors := make(map[string]bool)
+	for root := b.root; root != ""; {
+		ancestors[root] = true
+		// a parent that was not seen as a head is still known by its root
+		ancestor, ok := t.blocks[root]
+		if !ok {
+			break
+		}
+		root = ancestor.parent
+	}
+
+	reorg := &types.Reorg{
+		Time:        time.Now(),
+		OldHeadSlot: old.slot,
+		OldHeadRoot: old.root,
+		NewHeadSlot: b.slot,
+		NewHeadRoot: b.root,
+	}
+	for cur, ok := *old, true; ok; cur, ok = t.blocks[cur.parent] {
+		if ancestors[cur.root] {
+			reorg.CommonAncestor = cur.root
+			break
+		}
+		reorg.Dropped = append(reorg.Dropped, cur.root)
+		if cur.parent == "" {
+			// no parent roots to follow
+			break
+		}
+	}
+	if reorg.CommonAncestor != "" {
+		if len(reorg.Dropped) == 0 {
+			// the old head is an ancestor of the new one, some heads in between were missed
+			return nil
+		}
+		reorg.Depth = len(reorg.Dropped)
+		return reorg
+	}
+
+	// without a common ancestor the new head may just follow heads that were missed,
+	// unless it is not ahead of the old head, which none of its descendants can be
+	if b.slot > old.slot {
+		return nil
+	}
+	reorg.Estimated = true
+	reorg.Dropped = t.droppedSince(b)
+	reorg.Depth = len(reorg.Dropped)
+	return reorg
+}
+
+// droppedSince returns the heads seen last that are not behind the new head, latest first.
+// They are linked by the order they were seen in rather than by parent roots.
+func (t *Tracker) droppedSince(head block) []string {
+	var dropped []string
+	for i := len(t.order) - 1; i >= 0; i-- {
+		b := t.blocks[t.order[i]]
+		if b.root == head.root {
+			continue
+		}
+		if b.slot < head.slot {
+			break
+		}
+		dropped = append(dropped, b.root)
+	}
+	return dropped
+}
+
+func (t *Tracker) add(b block) {
+	if _, ok := t.blocks[b.root]; !ok {
+		t.order = append(t.order, b.root)
+	}
+	t.blocks[b.root] = b
+	for len(t.order) > Window {
+		delete(t.blocks, t.order[0])
+		t.order = t.order[1:]
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	proto "github.com/alethio/eth2stats-proto"
+	"golang.org/x/time/rate"
+
+	"github.com/alethio/eth2stats-client/core/buffer"
+	"github.com/alethio/eth2stats-client/core/telemetry"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// Record collects chain heads and telemetry into the buffer until the context is cancelled,
+// so they can be replayed once the eth2stats server is reachable again.
+// It returns right away if buffering is disabled or the beacon node type has not been detected.
+func (c *Core) Record(ctx context.Context) {
+	if c.buffer == nil || c.beaconClient == nil {
+		return
+	}
+	c.log.Info("recording data until the eth2stats server is reachable again")
+
+	if c.metricsWatcher != nil {
+		go c.metricsWatcher.Run(ctx)
+	}
+
+	t := telemetry.New(&buffer.TelemetryRecorder{Queue: c.buffer}, c.beaconClient, c.metricsWatcher, c.contextWithToken, c.metrics, c.clock, c.knownCapabilities(), c.telemetryConfig())
+	go func() {
+		err := t.Run(ctx)
+		if err != nil {
+			c.log.Errorf("recording telemetry: %s", err)
+		}
+	}()
+
+	sub, err := c.beaconClient.SubscribeChainHeads(ctx)
+	if err != nil {
+		c.log.Errorf("recording chain heads: %s", err)
+		<-ctx.Done()
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		sub.Close()
+	}()
+
+	for head := range sub.Channel() {
+		c.recordHead(ctx, head)
+		c.bufferHead(head)
+	}
+	<-ctx.Done()
+}
+
+func (c *Core) bufferHead(head types.ChainHead) {
+	if c.buffer == nil {
+		return
+	}
+	err := c.buffer.Push(buffer.Entry{Kind: buffer.KindChainHead, ChainHead: &head})
+	if err != nil {
+		c.log.Errorf("buffering chain head: %s", err)
+	}
+}
+
+// replayBuffer sends everything recorded while the eth2stats server was unreachable.
+func (c *Core) replayBuffer(ctx context.Context) error {
+	if c.buffer == nil {
+		return nil
+	}
+
+	limiter := rate.NewLimiter(ReplayRateLimit, 1)
+	return c.buffer.Replay(func(e buffer.Entry) error {
+		err := limiter.Wait(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case e.Kind == buffer.KindChainHead && e.ChainHead != nil:
+			_, err = c.statsService.ChainHead(c.contextWithToken(ctx), chainHeadRequest(*e.ChainHead))
+		case e.Kind == buffer.KindPeers && e.Int != nil:
+			_, err = c.telemetryService.Peers(c.contextWithToken(ctx), &proto.PeersRequest{Peers: *e.Int})
+		case e.Kind == buffer.KindAttestations && e.Int != nil:
+			_, err = c.telemetryService.Attestations(c.contextWithToken(ctx), &proto.AttestationsRequest{AttestationsInPool: *e.Int})
+		case e.Kind == buffer.KindSyncing && e.Bool != nil:
+			_, err = c.telemetryService.Syncing(c.contextWithToken(ctx), &proto.SyncingRequest{Syncing: *e.Bool})
+		case e.Kind == buffer.KindMemoryUsage && e.Int != nil:
+			_, err = c.telemetryService.MemoryUsage(c.contextWithToken(ctx), &proto.MemoryUsageRequest{MemoryUsage: *e.Int})
+		default:
+			c.log.Warnf("skipping invalid buffered entry of kind %s", e.Kind)
+			return nil
+		}
+		if err != nil {
+			return &ServerError{Op: "replaying buffered " + string(e.Kind), Err: err}
+		}
+		return nil
+	})
+}
+
+// bufferPath pairs the buffer with the token, as the buffered data belongs to that identity.
+func (c *Core) bufferPath() string {
+	tokenPath := c.tokenPath()
+	return strings.TrimSuffix(tokenPath, filepath.Ext(tokenPath)) + ".buffer.jsonl"
+}
+
+func chainHeadRequest(head types.ChainHead) *proto.ChainHeadRequest {
+	return &proto.ChainHeadRequest{
+		HeadSlot:           head.HeadSlot,
+		HeadBlockRoot:      head.HeadBlockRoot,
+		FinalizedSlot:      head.FinalizedSlot,
+		FinalizedBlockRoot: head.FinalizedBlockRoot,
+		JustifiedSlot:      head.JustifiedSlot,
+		JustifiedBlockRoot: head.JustifiedBlockRoot,
+	}
+}
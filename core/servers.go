@@ -0,0 +1,429 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	proto "github.com/alethio/eth2stats-proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ServerConfig is one of several eth2stats servers a node reports to.
+type ServerConfig struct {
+	Addr      string
+	TLS       bool
+	TLSConfig TLSConfig
+	// NodeName overrides the name of the node on this server.
+	NodeName string
+	// TokenFile is resolved relative to DataFolder; defaults to the token file of the node, suffixed with the server address.
+	TokenFile string
+}
+
+// ServerStatus is the connection state of one of several eth2stats servers.
+type ServerStatus struct {
+	Addr      string `json:"addr"`
+	NodeName  string `json:"nodeName"`
+	Connected bool   `json:"connected"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// unsafeFileChars are replaced when deriving a token file name from a server address.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// ErrNoServerConnected is returned once none of several eth2stats servers is connected.
+var ErrNoServerConnected = errors.New("no eth2stats server connected")
+
+// server is one of several eth2stats servers, with its own token and connection state.
+type server struct {
+	config    ServerConfig
+	nodeName  string
+	tokenPath string
+	log       *logrus.Entry
+
+	stats     proto.Eth2StatsClient
+	telemetry proto.TelemetryClient
+
+	mu            sync.Mutex
+	token         string
+	connected     bool
+	lastErr       error
+	retryAt       time.Time
+	retryInterval time.Duration
+	// retrying is set while Run reconnects the server
+	retrying bool
+}
+
+// serverSet fans the data of a node out to several eth2stats servers. Every server connects and
+// retries on its own, so an unreachable server does not keep the others from getting data;
+// calls only fail once none of the servers is connected.
+type serverSet struct {
+	servers          []*server
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+
+	// what a server that reconnects needs to catch up
+	mu       sync.Mutex
+	connect  *proto.ConnectRequest
+	metadata metadata.MD
+	head     *proto.ChainHeadRequest
+}
+
+// Check interface
+var _ = proto.Eth2StatsClient((*serverSet)(nil))
+var _ = proto.TelemetryClient((*serverSet)(nil))
+
+func (c *Core) newServerSet() (*serverSet, error) {
+	set := &serverSet{
+		retryInterval:    c.config.Eth2stats.RetryInterval,
+		maxRetryInterval: c.config.Eth2stats.MaxRetryInterval,
+	}
+	if set.retryInterval <= 0 {
+		set.retryInterval = ServerRetryInterval
+	}
+	if set.maxRetryInterval < set.retryInterval {
+		set.maxRetryInterval = MaxServerRetryInterval
+		if set.maxRetryInterval < set.retryInterval {
+			set.maxRetryInterval = set.retryInterval
+		}
+	}
+	for _, config := range c.config.Eth2stats.Servers {
+		conn, err := dialServer(config.Addr, config.TLS, config.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+		s := &server{
+			config:    config,
+			nodeName:  config.NodeName,
+			tokenPath: c.serverTokenPath(config),
+			log:       c.log.WithField("server", config.Addr),
+			stats:     proto.NewEth2StatsClient(conn),
+			telemetry: proto.NewTelemetryClient(conn),
+		}
+		if s.nodeName == "" {
+			s.nodeName = c.config.Eth2stats.NodeName
+		}
+		if err := s.loadToken(); err != nil {
+			return nil, fmt.Errorf("loading auth token of %s: %s", config.Addr, err)
+		}
+		set.servers = append(set.servers, s)
+	}
+	return set, nil
+}
+
+// serverTokenPath keeps the tokens of the servers apart, as every server registers the node separately.
+func (c *Core) serverTokenPath(config ServerConfig) string {
+	if config.TokenFile != "" {
+		if filepath.IsAbs(config.TokenFile) {
+			return config.TokenFile
+		}
+		return filepath.Join(c.config.DataFolder, config.TokenFile)
+	}
+	tokenPath := c.tokenPath()
+	suffix := unsafeFileChars.ReplaceAllString(config.Addr, "_")
+	return strings.TrimSuffix(tokenPath, filepath.Ext(tokenPath)) + "-" + suffix + filepath.Ext(tokenPath)
+}
+
+func (s *server) loadToken() error {
+	dat, err := ioutil.ReadFile(s.tokenPath)
+	if os.IsNotExist(err) {
+		s.log.Warn("token file not found; will register as new client")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	s.token = string(dat)
+	return nil
+}
+
+func (s *server) updateToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		return nil
+	}
+	s.token = token
+	_ = os.MkdirAll(filepath.Dir(s.tokenPath), os.ModePerm)
+	return ioutil.WriteFile(s.tokenPath, []byte(token), 0644)
+}
+
+// context replaces the token of the node with the one of this server.
+func (s *server) context(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	delete(md, "token")
+
+	s.mu.Lock()
+	if s.token != "" {
+		md.Set("token", s.token)
+	}
+	s.mu.Unlock()
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func (s *server) isConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connected
+}
+
+func (s *server) setConnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		s.log.Info("connected to eth2stats server")
+	}
+	s.connected = true
+	s.lastErr = nil
+	s.retryInterval = 0
+	s.retrying = false
+}
+
+// fail disconnects the server until its next retry, backing off on failures in a row.
+func (s *server) fail(err error, retryInterval, maxRetryInterval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.retryInterval == 0 {
+		s.retryInterval = retryInterval
+	} else if s.retryInterval *= 2; s.retryInterval > maxRetryInterval {
+		s.retryInterval = maxRetryInterval
+	}
+	s.connected = false
+	s.lastErr = err
+	s.retrying = false
+	s.retryAt = time.Now().Add(s.retryInterval)
+	s.log.Errorf("%s; retrying in %s", err, s.retryInterval)
+}
+
+// claimRetry tells whether the server is due for a reconnect, and if so marks it as being reconnected.
+func (s *server) claimRetry(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.connected || s.retrying || now.Before(s.retryAt) {
+		return false
+	}
+	s.retrying = true
+	return true
+}
+
+func (s *server) status() ServerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := ServerStatus{
+		Addr:      s.config.Addr,
+		NodeName:  s.nodeName,
+		Connected: s.connected,
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+	return status
+}
+
+// connect registers the node with the server and catches it up with the last chain head.
+func (s *server) connect(ctx context.Context, in *proto.ConnectRequest, head *proto.ChainHeadRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, ServerCallTimeout)
+	defer cancel()
+
+	request := *in
+	request.Name = s.nodeName
+	resp, err := s.stats.Connect(s.context(ctx), &request)
+	if err != nil {
+		return &ServerError{Op: "connecting", Err: err}
+	}
+	if err := s.updateToken(resp.Token); err != nil {
+		return fmt.Errorf("writing connection token: %s", err)
+	}
+	if head != nil {
+		if _, err := s.stats.ChainHead(s.context(ctx), head); err != nil {
+			return &ServerError{Op: "sending chain head", Err: err}
+		}
+	}
+	return nil
+}
+
+// each makes a call on every connected server at once, disconnecting the ones it fails or times out on.
+func (set *serverSet) each(ctx context.Context, op string, call func(ctx context.Context, s *server) error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	connected := 0
+	for _, s := range set.servers {
+		if !s.isConnected() {
+			continue
+		}
+		wg.Add(1)
+		go func(s *server) {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(s.context(ctx), ServerCallTimeout)
+			err := call(callCtx, s)
+			cancel()
+			if err != nil {
+				s.fail(&ServerError{Op: op, Err: err}, set.retryInterval, set.maxRetryInterval)
+				return
+			}
+			mu.Lock()
+			connected++
+			mu.Unlock()
+		}(s)
+	}
+	wg.Wait()
+	if connected == 0 {
+		return ErrNoServerConnected
+	}
+	return nil
+}
+
+// Connect registers the node with every server at once. It only fails if none of them could be connected;
+// the others are retried by Run.
+func (set *serverSet) Connect(ctx context.Context, in *proto.ConnectRequest, opts ...grpc.CallOption) (*proto.ConnectResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	set.mu.Lock()
+	set.connect = in
+	set.metadata = md.Copy()
+	set.mu.Unlock()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []string
+	for _, s := range set.servers {
+		wg.Add(1)
+		go func(s *server) {
+			defer wg.Done()
+			if err := s.connect(ctx, in, nil); err != nil {
+				s.fail(err, set.retryInterval, set.maxRetryInterval)
+				mu.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %s", s.config.Addr, err))
+				mu.Unlock()
+				return
+			}
+			s.setConnected()
+		}(s)
+	}
+	wg.Wait()
+	if len(errs) == len(set.servers) {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("%s (%s)", ErrNoServerConnected, strings.Join(errs, "; "))
+	}
+	// every server has its own token, the node keeps none
+	return &proto.ConnectResponse{}, nil
+}
+
+// Run reconnects disconnected servers when their retry is due, until the context is cancelled.
+func (set *serverSet) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			set.mu.Lock()
+			in, md, head := set.connect, set.metadata, set.head
+			set.mu.Unlock()
+			if in == nil {
+				continue
+			}
+
+			// every server is retried on its own, so a slow one doesn't hold up the others
+			for _, s := range set.servers {
+				if !s.claimRetry(now) {
+					continue
+				}
+				go set.reconnect(metadata.NewOutgoingContext(ctx, md), s, in, head)
+			}
+		}
+	}
+}
+
+func (set *serverSet) reconnect(ctx context.Context, s *server, in *proto.ConnectRequest, head *proto.ChainHeadRequest) {
+	s.log.Info("reconnecting to eth2stats server")
+	err := s.connect(ctx, in, head)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		s.fail(err, set.retryInterval, set.maxRetryInterval)
+		return
+	}
+	s.setConnected()
+}
+
+func (set *serverSet) Status() []ServerStatus {
+	statuses := make([]ServerStatus, 0, len(set.servers))
+	for _, s := range set.servers {
+		statuses = append(statuses, s.status())
+	}
+	return statuses
+}
+
+func (set *serverSet) ChainHead(ctx context.Context, in *proto.ChainHeadRequest, opts ...grpc.CallOption) (*proto.ChainHeadResponse, error) {
+	set.mu.Lock()
+	set.head = in
+	set.mu.Unlock()
+
+	return &proto.ChainHeadResponse{}, set.each(ctx, "sending chain head", func(ctx context.Context, s *server) error {
+		_, err := s.stats.ChainHead(ctx, in)
+		return err
+	})
+}
+
+func (set *serverSet) Heartbeat(ctx context.Context, in *proto.HeartbeatRequest, opts ...grpc.CallOption) (*proto.HeartbeatResponse, error) {
+	return &proto.HeartbeatResponse{}, set.each(ctx, "sending heartbeat", func(ctx context.Context, s *server) error {
+		_, err := s.stats.Heartbeat(ctx, in)
+		return err
+	})
+}
+
+// Telemetry is deprecated in the protocol, the client does not use it.
+func (set *serverSet) Telemetry(ctx context.Context, in *proto.TelemetryRequest, opts ...grpc.CallOption) (*proto.TelemetryResponse, error) {
+	return &proto.TelemetryResponse{}, set.each(ctx, "sending telemetry", func(ctx context.Context, s *server) error {
+		_, err := s.stats.Telemetry(ctx, in)
+		return err
+	})
+}
+
+func (set *serverSet) Peers(ctx context.Context, in *proto.PeersRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, set.each(ctx, "sending peers count", func(ctx context.Context, s *server) error {
+		_, err := s.telemetry.Peers(ctx, in)
+		return err
+	})
+}
+
+func (set *serverSet) Attestations(ctx context.Context, in *proto.AttestationsRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, set.each(ctx, "sending attestations count", func(ctx context.Context, s *server) error {
+		_, err := s.telemetry.Attestations(ctx, in)
+		return err
+	})
+}
+
+func (set *serverSet) Syncing(ctx context.Context, in *proto.SyncingRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, set.each(ctx, "sending syncing status", func(ctx context.Context, s *server) error {
+		_, err := s.telemetry.Syncing(ctx, in)
+		return err
+	})
+}
+
+func (set *serverSet) MemoryUsage(ctx context.Context, in *proto.MemoryUsageRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, set.each(ctx, "sending mem usage", func(ctx context.Context, s *server) error {
+		_, err := s.telemetry.MemoryUsage(ctx, in)
+		return err
+	})
+}
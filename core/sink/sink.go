@@ -0,0 +1,201 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	proto "github.com/alethio/eth2stats-proto"
+	"github.com/golang/protobuf/jsonpb"
+	protobuf "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Server sends the data to the eth2stats server, which is the default.
+	Server = "server"
+	// Stdout writes the data as JSON lines to the standard output.
+	Stdout = "stdout"
+	// FilePrefix followed by a path writes the data as JSON lines to that file.
+	FilePrefix = "file:"
+)
+
+// Validate checks a sink spec: Server, Stdout or FilePrefix followed by a path.
+func Validate(spec string) error {
+	switch {
+	case spec == Server, spec == Stdout:
+		return nil
+	case strings.HasPrefix(spec, FilePrefix) && len(spec) > len(FilePrefix):
+		return nil
+	default:
+		return fmt.Errorf("unknown sink %q, expected %s, %s or %s<path>", spec, Server, Stdout, FilePrefix)
+	}
+}
+
+// Record is a message that would have been sent to the eth2stats server.
+type Record struct {
+	Time   time.Time `json:"time"`
+	Node   string    `json:"node"`
+	Method string    `json:"method"`
+	// Metadata of the call, apart from the token.
+	Metadata map[string][]string `json:"metadata,omitempty"`
+	Request  json.RawMessage     `json:"request"`
+}
+
+// Recorder is implemented by eth2stats services that keep what the client derives beyond the eth2stats
+// protocol, such as reorgs, finality and peer details. The protocol has no messages for these, so only
+// the local sinks record them; the eth2stats server, several servers and the buffer don't get them.
+type Recorder interface {
+	Record(ctx context.Context, method string, v interface{}) error
+}
+
+// Check interface
+var _ = Recorder((*JSONLines)(nil))
+
+// JSONLines implements the eth2stats services by writing every request as a Record on its own line.
+type JSONLines struct {
+	node string
+	w    io.Writer
+	mu   *sync.Mutex
+}
+
+// Check interface
+var _ = proto.Eth2StatsClient((*JSONLines)(nil))
+var _ = proto.TelemetryClient((*JSONLines)(nil))
+
+var (
+	filesMu sync.Mutex
+	// files are shared by every node writing to them, for as long as the process runs
+	files   = make(map[string]*os.File)
+	writeMu = make(map[io.Writer]*sync.Mutex)
+)
+
+// Open returns the sink for a spec other than Server, for the given node.
+func Open(spec string, node string) (*JSONLines, error) {
+	if err := Validate(spec); err != nil {
+		return nil, err
+	}
+	if spec == Server {
+		return nil, fmt.Errorf("the %s sink is not written locally", Server)
+	}
+
+	filesMu.Lock()
+	defer filesMu.Unlock()
+
+	var w io.Writer = os.Stdout
+	if strings.HasPrefix(spec, FilePrefix) {
+		path := filepath.Clean(spec[len(FilePrefix):])
+		f, ok := files[path]
+		if !ok {
+			_ = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+			var err error
+			f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, err
+			}
+			files[path] = f
+		}
+		w = f
+	}
+	if writeMu[w] == nil {
+		writeMu[w] = new(sync.Mutex)
+	}
+	return &JSONLines{
+		node: node,
+		w:    w,
+		mu:   writeMu[w],
+	}, nil
+}
+
+func (s *JSONLines) write(ctx context.Context, method string, in protobuf.Message) error {
+	var request bytes.Buffer
+	marshaler := jsonpb.Marshaler{EmitDefaults: true}
+	if err := marshaler.Marshal(&request, in); err != nil {
+		return err
+	}
+	return s.writeRecord(ctx, method, request.Bytes())
+}
+
+func (s *JSONLines) writeRecord(ctx context.Context, method string, request json.RawMessage) error {
+	record := Record{
+		Time:    time.Now(),
+		Node:    s.node,
+		Method:  method,
+		Request: request,
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for k, v := range md {
+			if k == "token" {
+				continue
+			}
+			if record.Metadata == nil {
+				record.Metadata = make(map[string][]string)
+			}
+			record.Metadata[k] = v
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+func (s *JSONLines) Connect(ctx context.Context, in *proto.ConnectRequest, opts ...grpc.CallOption) (*proto.ConnectResponse, error) {
+	// keep whatever token the client already has, there is no server to hand out a new one
+	var token string
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get("token")) > 0 {
+		token = md.Get("token")[0]
+	}
+	return &proto.ConnectResponse{Token: token}, s.write(ctx, "Connect", in)
+}
+
+func (s *JSONLines) ChainHead(ctx context.Context, in *proto.ChainHeadRequest, opts ...grpc.CallOption) (*proto.ChainHeadResponse, error) {
+	return &proto.ChainHeadResponse{}, s.write(ctx, "ChainHead", in)
+}
+
+func (s *JSONLines) Heartbeat(ctx context.Context, in *proto.HeartbeatRequest, opts ...grpc.CallOption) (*proto.HeartbeatResponse, error) {
+	return &proto.HeartbeatResponse{}, s.write(ctx, "Heartbeat", in)
+}
+
+// Telemetry is deprecated in the protocol, the client does not use it.
+func (s *JSONLines) Telemetry(ctx context.Context, in *proto.TelemetryRequest, opts ...grpc.CallOption) (*proto.TelemetryResponse, error) {
+	return &proto.TelemetryResponse{}, s.write(ctx, "Telemetry", in)
+}
+
+func (s *JSONLines) Peers(ctx context.Context, in *proto.PeersRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, s.write(ctx, "Peers", in)
+}
+
+func (s *JSONLines) Attestations(ctx context.Context, in *proto.AttestationsRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, s.write(ctx, "Attestations", in)
+}
+
+func (s *JSONLines) Syncing(ctx context.Context, in *proto.SyncingRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, s.write(ctx, "Syncing", in)
+}
+
+func (s *JSONLines) MemoryUsage(ctx context.Context, in *proto.MemoryUsageRequest, opts ...grpc.CallOption) (*proto.DefaultResponse, error) {
+	return &proto.DefaultResponse{}, s.write(ctx, "MemoryUsage", in)
+}
+
+// Record writes data the eth2stats protocol has no message for as a record of the given method.
+func (s *JSONLines) Record(ctx context.Context, method string, v interface{}) error {
+	request, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.writeRecord(ctx, method, request)
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/beacon/failover"
+	"github.com/alethio/eth2stats-client/core/finality"
+	"github.com/alethio/eth2stats-client/core/telemetry"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+// Status is a snapshot of what a Core knows about its beacon node and the eth2stats server.
+type Status struct {
+	NodeName        string `json:"nodeName"`
+	BeaconReachable bool   `json:"beaconReachable"`
+	// BeaconBackend is the address calls are routed to, if there are redundant beacon nodes.
+	BeaconBackend string               `json:"beaconBackend,omitempty"`
+	Connected     bool                 `json:"connected"`
+	LastHeartbeat *time.Time           `json:"lastHeartbeat"`
+	ChainHead     *types.ChainHead     `json:"chainHead"`
+	Capabilities  *beacon.Capabilities `json:"capabilities"`
+	Telemetry     telemetry.Data       `json:"telemetry"`
+	Finality      *types.Finality      `json:"finality,omitempty"`
+	// Reorgs counts the reorgs seen since the client started, the last of which is LastReorg.
+	Reorgs    uint64       `json:"reorgs"`
+	LastReorg *types.Reorg `json:"lastReorg,omitempty"`
+	// Servers is the state of every server, if the node reports to several.
+	Servers   []ServerStatus `json:"servers,omitempty"`
+	LastError string         `json:"lastError,omitempty"`
+}
+
+// Status returns the current status of the core. It is safe to call at any time.
+func (c *Core) Status() Status {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	status := Status{
+		NodeName:        c.config.Eth2stats.NodeName,
+		BeaconReachable: c.beaconErr == nil,
+		Connected:       c.connected,
+	}
+	if !c.lastHeartbeat.IsZero() {
+		lastHeartbeat := c.lastHeartbeat
+		status.LastHeartbeat = &lastHeartbeat
+	}
+	if c.lastHead != nil {
+		head := *c.lastHead
+		status.ChainHead = &head
+		if f, ok := finality.Evaluate(head, c.clock, c.finalityConfig(), time.Now()); ok {
+			status.Finality = &f
+		}
+	}
+	if f, ok := c.beaconClient.(*failover.Client); ok {
+		status.BeaconBackend = f.Active()
+	}
+	if c.capabilities != nil {
+		capabilities := *c.capabilities
+		status.Capabilities = &capabilities
+	}
+	status.Reorgs, status.LastReorg = c.config.History.reorgStatus()
+	if c.servers != nil {
+		status.Servers = c.servers.Status()
+	}
+	if c.lastErr != nil {
+		status.LastError = c.lastErr.Error()
+	}
+	if c.telemetry != nil {
+		status.Telemetry = c.telemetry.Data()
+		if c.telemetry.BeaconError() != nil {
+			status.BeaconReachable = false
+		}
+	}
+	return status
+}
+
+func (c *Core) setConnected(connected bool, err error) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.connected = connected
+	if err != nil {
+		c.lastErr = err
+	}
+	var beaconErr *BeaconError
+	if errors.As(err, &beaconErr) {
+		c.beaconErr = beaconErr
+	}
+}
+
+func (c *Core) recordHeartbeat() {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.lastHeartbeat = time.Now()
+}
+
+// recordCheckpoints records the checkpoints of a head that was polled rather than streamed. It may be behind
+// the heads streamed meanwhile, so it is not tracked for reorgs and doesn't replace a later head.
+func (c *Core) recordCheckpoints(head types.ChainHead) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.beaconErr = nil
+	if c.lastHead != nil && head.HeadSlot < c.lastHead.HeadSlot {
+		latest := *c.lastHead
+		latest.FinalizedSlot, latest.FinalizedBlockRoot = head.FinalizedSlot, head.FinalizedBlockRoot
+		latest.JustifiedSlot, latest.JustifiedBlockRoot = head.JustifiedSlot, head.JustifiedBlockRoot
+		c.lastHead = &latest
+		return
+	}
+	c.lastHead = &head
+	c.metrics.SeenHead(head.HeadSlot)
+}
+
+func (c *Core) recordHead(ctx context.Context, head types.ChainHead) {
+	c.statusMu.Lock()
+	c.lastHead = &head
+	c.beaconErr = nil
+	c.metrics.SeenHead(head.HeadSlot)
+	c.statusMu.Unlock()
+
+	reorg := c.config.History.observeHead(head)
+	if reorg != nil {
+		c.reportReorg(ctx, *reorg)
+	}
+}
@@ -0,0 +1,155 @@
+package telemetry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/types"
+)
+
+type peerEvent struct {
+	at         time.Time
+	disconnect bool
+	// lifetime of a disconnected peer, if its connect was seen
+	lifetime time.Duration
+}
+
+type connectedPeer struct {
+	since time.Time
+	// false for peers that were already connected in the first list, whose connect time is unknown
+	seenConnect bool
+}
+
+// ChurnTracker diffs successive lists of connected peers to tell how often they change.
+type ChurnTracker struct {
+	window    time.Duration
+	longLived time.Duration
+
+	mu        sync.Mutex
+	started   time.Time
+	last      time.Time
+	connected map[string]connectedPeer
+	// connects and disconnects within the window, oldest first
+	events []peerEvent
+}
+
+// NewChurnTracker averages rates over the window, and counts peers connected for at least longLived as long-lived.
+// Zero values are defaulted.
+func NewChurnTracker(window, longLived time.Duration) *ChurnTracker {
+	if window == 0 {
+		window = ChurnWindow
+	}
+	if longLived == 0 {
+		longLived = LongLivedPeerAge
+	}
+	return &ChurnTracker{
+		window:    window,
+		longLived: longLived,
+	}
+}
+
+// Observe records a peer list taken at the given time, with lists expected every interval, and returns the churn so far.
+// It is nil for the first list, which there is nothing to compare with, and until MinChurnCoverage of the window is covered.
+// A list that comes more than ChurnGapPolls intervals after the previous one is taken as a first list again.
+func (c *ChurnTracker) Observe(peers []types.Peer, now time.Time, interval time.Duration) *types.PeerChurn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.connected != nil && now.Sub(c.last) > ChurnGapPolls*interval {
+		log.Debugf("no peer list for %s, starting peer churn over", now.Sub(c.last).Round(time.Second))
+		c.connected = nil
+		c.events = nil
+	}
+	c.last = now
+
+	current := make(map[string]bool)
+	for _, p := range peers {
+		if p.State != "" && p.State != beacon.PeerConnected {
+			continue
+		}
+		id := p.ID
+		if id == "" {
+			id = p.Address
+		}
+		if id != "" {
+			current[id] = true
+		}
+	}
+
+	if c.connected == nil {
+		c.started = now
+		c.connected = make(map[string]connectedPeer)
+		for id := range current {
+			c.connected[id] = connectedPeer{since: now}
+		}
+		return nil
+	}
+
+	for id, peer := range c.connected {
+		if current[id] {
+			continue
+		}
+		event := peerEvent{at: now, disconnect: true}
+		if peer.seenConnect {
+			event.lifetime = now.Sub(peer.since)
+		}
+		c.events = append(c.events, event)
+		delete(c.connected, id)
+	}
+	for id := range current {
+		if _, ok := c.connected[id]; ok {
+			continue
+		}
+		c.events = append(c.events, peerEvent{at: now})
+		c.connected[id] = connectedPeer{since: now, seenConnect: true}
+	}
+
+	// forget what happened before the window
+	cutoff := now.Add(-c.window)
+	i := 0
+	for i < len(c.events) && c.events[i].at.Before(cutoff) {
+		i++
+	}
+	c.events = c.events[i:]
+
+	return c.churn(now)
+}
+
+func (c *ChurnTracker) churn(now time.Time) *types.PeerChurn {
+	// rates are over the part of the window the tracker has been running for
+	window := c.window
+	if elapsed := now.Sub(c.started); elapsed < window {
+		if elapsed < time.Duration(float64(c.window)*MinChurnCoverage) {
+			return nil
+		}
+		window = elapsed
+	}
+	churn := &types.PeerChurn{}
+	var connects, disconnects, lifetimes int
+	var lifetime time.Duration
+	for _, e := range c.events {
+		if !e.disconnect {
+			connects++
+			continue
+		}
+		disconnects++
+		if e.lifetime > 0 {
+			lifetimes++
+			lifetime += e.lifetime
+		}
+	}
+	churn.ConnectsPerMinute = float64(connects) / window.Minutes()
+	churn.DisconnectsPerMinute = float64(disconnects) / window.Minutes()
+	if lifetimes > 0 {
+		churn.AverageLifetime = (lifetime / time.Duration(lifetimes)).Seconds()
+	}
+
+	// the age of peers from the first list is a lower bound, which is enough to tell they lived long
+	for _, peer := range c.connected {
+		if now.Sub(peer.since) >= c.longLived {
+			churn.LongLived++
+		}
+	}
+	return churn
+}
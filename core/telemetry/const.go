@@ -4,7 +4,20 @@ import (
 	"time"
 )
 
+// Defaults for the zero values of Config.
 const (
 	PollingInterval      = 12 * time.Second
 	MemoryUsageThreshold = 10 * 1024 * 1024
+	PerformanceTimeout   = 2 * time.Minute
+	ChurnWindow          = 10 * time.Minute
+	LongLivedPeerAge     = time.Hour
+)
+
+const (
+	// ChurnGapPolls is how many polling intervals may pass between two peer lists before churn starts over,
+	// as the peer changes in between were not seen.
+	ChurnGapPolls = 5
+	// MinChurnCoverage is the part of the churn window peer lists have to cover before rates are reported,
+	// as a few seconds of changes say little about the rate.
+	MinChurnCoverage = 0.25
 )
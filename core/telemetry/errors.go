@@ -0,0 +1,19 @@
+package telemetry
+
+import (
+	"fmt"
+)
+
+// SendError is returned when a telemetry value could not be delivered to the eth2stats server.
+type SendError struct {
+	Metric string
+	Err    error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("telemetry: sending %s: %s", e.Metric, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
@@ -3,135 +3,329 @@ package telemetry
 import (
 	"context"
 	"math"
+	"sync"
 	"time"
 
 	proto "github.com/alethio/eth2stats-proto"
 	"github.com/sirupsen/logrus"
 
 	"github.com/alethio/eth2stats-client/beacon"
+	"github.com/alethio/eth2stats-client/clock"
+	"github.com/alethio/eth2stats-client/core/sink"
+	"github.com/alethio/eth2stats-client/exporter"
+	"github.com/alethio/eth2stats-client/types"
 	metricsWatcher "github.com/alethio/eth2stats-client/watcher/metrics"
 )
 
 var log = logrus.WithField("module", "telemetry")
 
+// Data holds the last known telemetry values; nil means the value is not known (yet).
+type Data struct {
+	Peers              *int64 `json:"peers"`
+	AttestationsInPool *int64 `json:"attestationsInPool"`
+	// RecentAttestationsInPool counts the pool of the last beacon.AttestationPoolSlots slots only,
+	// for nodes that can't tell the size of their whole pool; it is not sent to the eth2stats server.
+	RecentAttestationsInPool *int64 `json:"recentAttestationsInPool,omitempty"`
+	Syncing                  *bool  `json:"syncing"`
+	MemoryUsage              *int64 `json:"memoryUsage"`
+
+	PeerStats *types.PeerStats `json:"peerStats,omitempty"`
+
+	Validators           []types.Validator            `json:"validators,omitempty"`
+	ValidatorPerformance []types.ValidatorPerformance `json:"validatorPerformance,omitempty"`
+}
+
+type Config struct {
+	// PollingInterval is used while the clock can't tell when the next slot starts.
+	PollingInterval time.Duration
+	// MemoryUsageThreshold is the change in bytes from which memory usage is sent again.
+	MemoryUsageThreshold int64
+	// Validators are the indices or pubkeys of the watched validators.
+	Validators []string
+	// Churn is kept by the caller, so peer churn is not lost when telemetry is set up again; a new one is used if nil.
+	Churn *ChurnTracker
+}
+
 type Telemetry struct {
+	config  Config
 	service proto.TelemetryClient
 
 	beaconClient     beacon.Client
 	metricsWatcher   *metricsWatcher.Watcher
-	contextWithToken func() context.Context
+	contextWithToken func(context.Context) context.Context
+	metrics          *exporter.NodeMetrics
+	clock            *clock.Clock
+	capabilities     beacon.Capabilities
 
-	data struct {
-		Peers              *int64
-		AttestationsInPool *int64
-		Syncing            *bool
-		MemoryUsage        *int64
-	}
+	// epochs the validators and their performance were last polled for
+	validatorsEpoch  *uint64
+	performanceEpoch *uint64
+
+	mu        sync.Mutex
+	data      Data
+	beaconErr error
 }
 
-func New(service proto.TelemetryClient, beaconClient beacon.Client, watcher *metricsWatcher.Watcher, contextWithToken func() context.Context) *Telemetry {
+func New(service proto.TelemetryClient, beaconClient beacon.Client, watcher *metricsWatcher.Watcher, contextWithToken func(context.Context) context.Context, metrics *exporter.NodeMetrics, chainClock *clock.Clock, capabilities beacon.Capabilities, config Config) *Telemetry {
+	if config.PollingInterval == 0 {
+		config.PollingInterval = PollingInterval
+	}
+	if config.MemoryUsageThreshold == 0 {
+		config.MemoryUsageThreshold = MemoryUsageThreshold
+	}
+	if config.Churn == nil {
+		config.Churn = NewChurnTracker(ChurnWindow, LongLivedPeerAge)
+	}
+
 	return &Telemetry{
+		config:           config,
 		service:          service,
 		beaconClient:     beaconClient,
 		metricsWatcher:   watcher,
 		contextWithToken: contextWithToken,
+		metrics:          metrics,
+		clock:            chainClock,
+		capabilities:     capabilities,
 	}
 }
 
-func (t *Telemetry) Run(ctx context.Context) {
-	for {
-		// Check if the service needs to stop yet.
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			break
+// Run polls and sends telemetry until the context is cancelled, once per slot if the clock
+// knows the genesis time and every polling interval otherwise. It returns an error as soon as a value can't be delivered to the eth2stats server.
+func (t *Telemetry) Run(ctx context.Context) error {
+	// unsupported collectors are skipped entirely
+	pollers := []func(context.Context) error{
+		t.pollPeers,
+	}
+	if t.capabilities.AttestationsInPool {
+		pollers = append(pollers, t.pollAttestations)
+	}
+	if t.capabilities.RecentAttestationsInPool {
+		pollers = append(pollers, t.pollRecentAttestations)
+	}
+	if t.capabilities.SyncStatus {
+		pollers = append(pollers, t.pollSyncing)
+	}
+	pollers = append(pollers, t.pollMemUsage)
+	if len(t.config.Validators) > 0 {
+		if t.capabilities.Validators {
+			pollers = append(pollers, t.pollValidators)
+		} else {
+			log.Warn("the beacon node does not support validator queries, not watching validators")
 		}
+	}
+	for {
 		log.Trace("sending telemetry")
 
-		t.pollPeers()
-		t.pollAttestations()
-		t.pollSyncing()
-		t.pollMemUsage()
+		for _, poll := range pollers {
+			if err := poll(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
 
 		log.Trace("done sending telemetry")
 
-		time.Sleep(PollingInterval)
+		next, ok := t.clock.NextSlotTick(time.Now())
+		if !ok {
+			next = time.Now().Add(t.config.PollingInterval)
+		}
+		// Check if the service needs to stop yet.
+		if !clock.SleepUntil(ctx, next) {
+			return nil
+		}
+	}
+}
+
+// interval is how often telemetry is polled at the moment.
+func (t *Telemetry) interval() time.Duration {
+	if _, ok := t.clock.NextSlotTick(time.Now()); ok {
+		return t.clock.SlotDuration()
 	}
+	return t.config.PollingInterval
 }
 
-func (t *Telemetry) pollPeers() {
-	peers, err := t.beaconClient.GetPeerCount()
+// Data returns a copy of the last known telemetry values.
+func (t *Telemetry) Data() Data {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.data
+}
+
+// BeaconError returns the error of the last peer count poll, which every beacon node supports.
+func (t *Telemetry) BeaconError() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.beaconErr
+}
+
+func (t *Telemetry) pollPeers(ctx context.Context) error {
+	done := t.metrics.TimeRPC("beacon", "GetPeers")
+	callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+	list, err := t.beaconClient.GetPeers(callCtx)
+	cancel()
+	done()
+	var stats types.PeerStats
+	switch err {
+	case nil:
+		stats = beacon.SummarizePeers(list)
+		stats.Churn = t.config.Churn.Observe(list, time.Now(), t.interval())
+	case beacon.NotImplemented:
+		// the count alone is still worth reporting; other errors are passed on, as nodes often
+		// count their peers from the same list
+		done := t.metrics.TimeRPC("beacon", "GetPeerCount")
+		callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+		stats.Connected, err = t.beaconClient.GetPeerCount(callCtx)
+		cancel()
+		done()
+	}
+	t.mu.Lock()
+	t.beaconErr = err
+	t.mu.Unlock()
 	if err != nil {
-		log.Errorf("getting peer count: %s", err)
-		return
+		log.Errorf("getting peers: %s", err)
+		return nil
 	}
+	peers := stats.Connected
 	log.Tracef("peers: %d", peers)
+	t.metrics.SeenPeers(stats)
+
+	t.mu.Lock()
+	statsChanged := t.data.PeerStats == nil || !samePeerStats(*t.data.PeerStats, stats)
+	t.data.PeerStats = &stats
+	t.mu.Unlock()
+	if recorder, ok := t.service.(sink.Recorder); ok && statsChanged {
+		if err := recorder.Record(t.contextWithToken(ctx), "PeerStats", stats); err != nil {
+			return &SendError{Metric: "peer stats", Err: err}
+		}
+	}
 
 	if t.data.Peers == nil || *t.data.Peers != peers {
+		t.mu.Lock()
 		t.data.Peers = &peers
+		t.mu.Unlock()
 
-		_, err := t.service.Peers(t.contextWithToken(), &proto.PeersRequest{Peers: peers})
+		done := t.metrics.TimeRPC("eth2stats", "Peers")
+		_, err := t.service.Peers(t.contextWithToken(ctx), &proto.PeersRequest{Peers: peers})
+		done()
 		if err != nil {
-			log.Fatalf("sending peers count: %s", err)
+			return &SendError{Metric: "peers count", Err: err}
 		}
 	}
+	return nil
 }
 
-func (t *Telemetry) pollAttestations() {
-	attestations, err := t.beaconClient.GetAttestationsInPoolCount()
-	if err != nil {
-		if err == beacon.NotImplemented {
-			// feature not available, skip
-			return
+func samePeerStats(a, b types.PeerStats) bool {
+	return a.Connected == b.Connected && a.Inbound == b.Inbound && a.Outbound == b.Outbound &&
+		sameCounts(a.States, b.States) && sameCounts(a.Clients, b.Clients)
+}
+
+func sameCounts(a, b map[string]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
 		}
+	}
+	return true
+}
+
+func (t *Telemetry) pollAttestations(ctx context.Context) error {
+	done := t.metrics.TimeRPC("beacon", "GetAttestationsInPoolCount")
+	callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+	attestations, err := t.beaconClient.GetAttestationsInPoolCount(callCtx)
+	cancel()
+	done()
+	if err != nil {
 		log.Errorf("getting attestations in pool: %s", err)
-		return
+		return nil
 	}
 	log.Tracef("attestations: %d", attestations)
+	t.metrics.SeenAttestationsInPool(attestations)
 
 	if t.data.AttestationsInPool == nil || *t.data.AttestationsInPool != attestations {
+		t.mu.Lock()
 		t.data.AttestationsInPool = &attestations
+		t.mu.Unlock()
 
-		_, err := t.service.Attestations(t.contextWithToken(), &proto.AttestationsRequest{AttestationsInPool: attestations})
+		done := t.metrics.TimeRPC("eth2stats", "Attestations")
+		_, err := t.service.Attestations(t.contextWithToken(ctx), &proto.AttestationsRequest{AttestationsInPool: attestations})
+		done()
 		if err != nil {
-			log.Fatalf("sending attestations count: %s", err)
+			return &SendError{Metric: "attestations count", Err: err}
 		}
 	}
+	return nil
 }
 
-func (t *Telemetry) pollSyncing() {
-	syncing, err := t.beaconClient.GetSyncStatus()
+// pollRecentAttestations keeps the count of the recent attestations in pool apart from the whole pool,
+// as the eth2stats server could not tell them from each other.
+func (t *Telemetry) pollRecentAttestations(ctx context.Context) error {
+	done := t.metrics.TimeRPC("beacon", "GetRecentAttestationsInPoolCount")
+	callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+	attestations, err := t.beaconClient.GetRecentAttestationsInPoolCount(callCtx)
+	cancel()
+	done()
+	if err != nil {
+		log.Errorf("getting recent attestations in pool: %s", err)
+		return nil
+	}
+	log.Tracef("recent attestations: %d", attestations)
+	t.metrics.SeenRecentAttestationsInPool(attestations)
+
+	t.mu.Lock()
+	t.data.RecentAttestationsInPool = &attestations
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Telemetry) pollSyncing(ctx context.Context) error {
+	done := t.metrics.TimeRPC("beacon", "GetSyncStatus")
+	callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+	syncing, err := t.beaconClient.GetSyncStatus(callCtx)
+	cancel()
+	done()
 	if err != nil {
-		if err == beacon.NotImplemented {
-			// feature not available, skip
-			return
-		}
 		log.Errorf("getting sync status: %s", err)
-		return
+		return nil
 	}
 	log.Tracef("node syncing: %t", syncing)
 
 	if t.data.Syncing == nil || *t.data.Syncing != syncing {
+		t.mu.Lock()
 		t.data.Syncing = &syncing
+		t.mu.Unlock()
 
-		_, err := t.service.Syncing(t.contextWithToken(), &proto.SyncingRequest{Syncing: syncing})
+		done := t.metrics.TimeRPC("eth2stats", "Syncing")
+		_, err := t.service.Syncing(t.contextWithToken(ctx), &proto.SyncingRequest{Syncing: syncing})
+		done()
 		if err != nil {
-			log.Fatalf("sending syncing status: %s", err)
+			return &SendError{Metric: "syncing status", Err: err}
 		}
 	}
+	return nil
 }
 
-func (t *Telemetry) pollMemUsage() {
+func (t *Telemetry) pollMemUsage(ctx context.Context) error {
 	memUsagePointer := t.metricsWatcher.GetMemUsage()
 	if memUsagePointer != nil {
-		if t.data.MemoryUsage == nil || (math.Abs(float64(*t.data.MemoryUsage-*memUsagePointer)) > MemoryUsageThreshold) {
+		if t.data.MemoryUsage == nil || (math.Abs(float64(*t.data.MemoryUsage-*memUsagePointer)) > float64(t.config.MemoryUsageThreshold)) {
+			t.mu.Lock()
 			t.data.MemoryUsage = memUsagePointer
+			t.mu.Unlock()
 
-			_, err := t.service.MemoryUsage(t.contextWithToken(), &proto.MemoryUsageRequest{MemoryUsage: *memUsagePointer})
+			done := t.metrics.TimeRPC("eth2stats", "MemoryUsage")
+			_, err := t.service.MemoryUsage(t.contextWithToken(ctx), &proto.MemoryUsageRequest{MemoryUsage: *memUsagePointer})
+			done()
 			if err != nil {
-				log.Fatalf("sending mem usage: %s", err)
+				return &SendError{Metric: "mem usage", Err: err}
 			}
 		}
 	}
+	return nil
 }
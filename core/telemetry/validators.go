@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/alethio/eth2stats-client/beacon"
+)
+
+// pollValidators refreshes the watched validators once per epoch and evaluates their duties
+// of the epoch before last, which is the latest one whose attestations can no longer be included.
+// The eth2stats protocol has no messages for validators yet, so the results are only kept
+// for the local status and metrics.
+func (t *Telemetry) pollValidators(ctx context.Context) error {
+	slot, ok := t.clock.CurrentSlot()
+	if !ok {
+		return nil
+	}
+	epoch := t.clock.EpochOf(slot)
+	if t.validatorsEpoch != nil && *t.validatorsEpoch == epoch {
+		return nil
+	}
+
+	done := t.metrics.TimeRPC("beacon", "GetValidators")
+	callCtx, cancel := context.WithTimeout(ctx, beacon.CallTimeout)
+	validators, err := t.beaconClient.GetValidators(callCtx, t.config.Validators)
+	cancel()
+	done()
+	if err != nil {
+		log.Errorf("getting validators: %s", err)
+		return nil
+	}
+	log.Tracef("validators: %d", len(validators))
+	if len(validators) < len(t.config.Validators) {
+		log.Warnf("only %d of %d watched validators are known to the beacon node", len(validators), len(t.config.Validators))
+	}
+	for _, v := range validators {
+		t.metrics.SeenValidator(v)
+	}
+
+	t.mu.Lock()
+	t.data.Validators = validators
+	t.mu.Unlock()
+	t.validatorsEpoch = &epoch
+
+	if epoch < 2 || len(validators) == 0 {
+		return nil
+	}
+	evaluated := epoch - 2
+	if t.performanceEpoch != nil && *t.performanceEpoch >= evaluated {
+		return nil
+	}
+
+	indices := make([]uint64, 0, len(validators))
+	for _, v := range validators {
+		indices = append(indices, v.Index)
+	}
+
+	// going through every block of two epochs takes longer than a regular call
+	done = t.metrics.TimeRPC("beacon", "GetValidatorPerformance")
+	callCtx, cancel = context.WithTimeout(ctx, PerformanceTimeout)
+	performance, err := t.beaconClient.GetValidatorPerformance(callCtx, evaluated, indices)
+	cancel()
+	done()
+	if err != nil {
+		// the next epoch is evaluated in its turn, this one is skipped
+		log.Errorf("getting validator performance for epoch %d: %s", evaluated, err)
+		return nil
+	}
+	for _, p := range performance {
+		t.metrics.SeenValidatorPerformance(p)
+
+		l := log.WithField("validator", p.Index).WithField("epoch", p.Epoch)
+		if !p.AttestationIncluded {
+			l.Warn("attestation was not included")
+		}
+		if p.ProposalsMissed > 0 {
+			l.Warnf("missed %d of %d block proposals", p.ProposalsMissed, p.ProposalsScheduled)
+		}
+	}
+
+	t.mu.Lock()
+	t.data.ValidatorPerformance = performance
+	t.mu.Unlock()
+	t.performanceEpoch = &evaluated
+	return nil
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSVersions are the accepted values of TLSConfig.MinVersion.
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig secures the connection to an eth2stats server beyond the system roots.
+// The files are read again on every handshake after they changed, so rotated certificates
+// are picked up without restarting.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of the CAs to trust instead of the system roots.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name the server certificate is verified against, which is the host of the address by default.
+	ServerName string
+	// MinVersion is one of TLSVersions; empty uses the Go default.
+	MinVersion string
+}
+
+func (config TLSConfig) problems() []error {
+	var problems []error
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		problems = append(problems, errors.New("a client certificate needs both tls-cert and tls-key"))
+	}
+	if _, ok := TLSVersions[config.MinVersion]; config.MinVersion != "" && !ok {
+		problems = append(problems, fmt.Errorf("unknown tls-min-version %q, expected 1.0, 1.1, 1.2 or 1.3", config.MinVersion))
+	}
+	for _, file := range []string{config.CAFile, config.CertFile, config.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
+
+// NewTLSConfig returns the TLS config to connect to the eth2stats server at addr.
+func NewTLSConfig(addr string, config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: config.ServerName,
+		MinVersion: TLSVersions[config.MinVersion],
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	if config.CAFile == "" && config.CertFile == "" {
+		return tlsConfig, nil
+	}
+
+	files := &tlsFiles{config: config}
+	if err := files.reload(); err != nil {
+		return nil, err
+	}
+	if config.CertFile != "" {
+		tlsConfig.GetClientCertificate = files.clientCertificate
+	}
+	if config.CAFile != "" {
+		// the roots of a tls.Config can't change, so the chain is verified against the current bundle instead
+		serverName := tlsConfig.ServerName
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return files.verify(serverName, rawCerts)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// tlsFiles holds the CA bundle and client certificate last read from disk.
+type tlsFiles struct {
+	config TLSConfig
+
+	mu       sync.Mutex
+	modTimes map[string]time.Time
+	roots    *x509.CertPool
+	cert     *tls.Certificate
+}
+
+// reload reads the files again if any of them changed since the last time.
+// A failed reload keeps the previous files, as rotation may be caught halfway.
+func (f *tlsFiles) reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	modTimes := make(map[string]time.Time)
+	changed := f.modTimes == nil
+	for _, file := range []string{f.config.CAFile, f.config.CertFile, f.config.KeyFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+		if !info.ModTime().Equal(f.modTimes[file]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var roots *x509.CertPool
+	if f.config.CAFile != "" {
+		pem, err := ioutil.ReadFile(f.config.CAFile)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", f.config.CAFile)
+		}
+	}
+	var cert *tls.Certificate
+	if f.config.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(f.config.CertFile, f.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %s", err)
+		}
+		cert = &c
+	}
+
+	if f.modTimes != nil {
+		log.Infof("reloaded TLS certificates of the eth2stats server connection")
+	}
+	f.modTimes = modTimes
+	f.roots = roots
+	f.cert = cert
+	return nil
+}
+
+func (f *tlsFiles) current() (*x509.CertPool, *tls.Certificate) {
+	if err := f.reload(); err != nil {
+		log.Errorf("reloading TLS certificates: %s; using the previous ones", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.roots, f.cert
+}
+
+func (f *tlsFiles) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	_, cert := f.current()
+	return cert, nil
+}
+
+func (f *tlsFiles) verify(serverName string, rawCerts [][]byte) error {
+	roots, _ := f.current()
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("parsing server certificate: %s", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return errors.New("server sent no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -2,9 +2,10 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 
 	"google.golang.org/grpc/metadata"
 )
@@ -12,42 +13,51 @@ import (
 const TokenFile = "token.dat"
 
 func (c *Core) searchToken() error {
-	log.Debug("looking for existing token")
-	fileName := path.Join(c.config.DataFolder, TokenFile)
+	c.log.Debug("looking for existing token")
+	fileName := c.tokenPath()
 	dat, err := ioutil.ReadFile(fileName)
 	if os.IsNotExist(err) {
-		log.Warn("token file not found; will register as new client")
+		c.log.Warn("token file not found; will register as new client")
 		return nil
 	} else if err != nil {
-		log.Error(err)
+		c.log.Error(err)
 
 		return err
 	}
 
-	log.Debug("found token")
+	c.log.Debug("found token")
 	c.token = string(dat)
 	return nil
 }
 
 func (c *Core) writeToken(token string) error {
-	fileName := path.Join(c.config.DataFolder, TokenFile)
-	log.Debug("persisting token to disk")
-	_ = os.MkdirAll(c.config.DataFolder, os.ModePerm)
+	fileName := c.tokenPath()
+	c.log.Debug("persisting token to disk")
+	_ = os.MkdirAll(filepath.Dir(fileName), os.ModePerm)
 	err := ioutil.WriteFile(fileName, []byte(token), 0644)
 	if err != nil {
-		log.Error(err)
+		c.log.Error(err)
 
 		return err
 	}
 
-	log.Debug("done persisting token")
+	c.log.Debug("done persisting token")
 
 	return nil
 }
 
-func (c *Core) contextWithToken() context.Context {
-	ctx := context.Background()
+func (c *Core) tokenPath() string {
+	tokenFile := c.config.TokenFile
+	if tokenFile == "" {
+		tokenFile = TokenFile
+	}
+	if filepath.IsAbs(tokenFile) {
+		return tokenFile
+	}
+	return filepath.Join(c.config.DataFolder, tokenFile)
+}
 
+func (c *Core) contextWithToken(ctx context.Context) context.Context {
 	// if we found any token persisted, use that to identify the client with the server
 	if c.token != "" {
 		ctx = metadata.AppendToOutgoingContext(ctx, "token", c.token)
@@ -56,14 +66,15 @@ func (c *Core) contextWithToken() context.Context {
 	return ctx
 }
 
-func (c *Core) updateToken(token string) {
+func (c *Core) updateToken(token string) error {
 	if c.token == token {
-		return
+		return nil
 	}
 
 	c.token = token
 	err := c.writeToken(token)
 	if err != nil {
-		log.Fatalf("writing connection token: %s", err)
+		return fmt.Errorf("writing connection token: %s", err)
 	}
+	return nil
 }
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/alethio/eth2stats-client/core/buffer"
+	"github.com/alethio/eth2stats-client/core/sink"
+)
+
+// BeaconNodeTypes are the supported values of BeaconNodeConfig.Type.
+var BeaconNodeTypes = []string{AutoDetect, "prysm", "lighthouse", "teku", "nimbus", "v1"}
+
+// Problems lists the mistakes in the config that can be found without contacting anything.
+func (config Config) Problems() []error {
+	var problems []error
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, a...))
+	}
+
+	if err := sink.Validate(config.Eth2stats.Sink); err != nil {
+		add("sink: %s", err)
+	}
+	// other sinks don't contact the server
+	if config.Eth2stats.Sink == sink.Server && len(config.Eth2stats.Servers) > 0 {
+		tokenFiles := make(map[string]bool)
+		for i, server := range config.Eth2stats.Servers {
+			if server.Addr == "" {
+				add("eth2stats.servers[%d].addr: missing server address", i)
+			} else if _, _, err := net.SplitHostPort(server.Addr); err != nil {
+				add("eth2stats.servers[%d].addr: expected host:port, got %q", i, server.Addr)
+			}
+			if server.TokenFile != "" {
+				if tokenFiles[server.TokenFile] {
+					add("eth2stats.servers[%d].token-file: %s is used by another server", i, server.TokenFile)
+				}
+				tokenFiles[server.TokenFile] = true
+			}
+			if server.TLS {
+				for _, err := range server.TLSConfig.problems() {
+					add("eth2stats.servers[%d]: %s", i, err)
+				}
+			}
+		}
+	} else if config.Eth2stats.Sink == sink.Server {
+		if config.Eth2stats.ServerAddr == "" {
+			add("eth2stats.addr: missing server address")
+		} else if _, _, err := net.SplitHostPort(config.Eth2stats.ServerAddr); err != nil {
+			add("eth2stats.addr: expected host:port, got %q", config.Eth2stats.ServerAddr)
+		}
+		if config.Eth2stats.TLS {
+			for _, err := range config.Eth2stats.TLSConfig.problems() {
+				add("eth2stats: %s", err)
+			}
+		}
+	}
+	if config.Eth2stats.NodeName == "" {
+		add("eth2stats.node-name: missing node name")
+	}
+
+	nodeType := config.BeaconNode.Type
+	if nodeType == "" {
+		add("beacon.type: missing node type, one of %s", strings.Join(BeaconNodeTypes, ", "))
+	} else if !isBeaconNodeType(nodeType) {
+		add("beacon.type: unknown node type %q, expected one of %s", nodeType, strings.Join(BeaconNodeTypes, ", "))
+	}
+	if len(config.BeaconNode.Addrs) == 0 {
+		add("beacon.addr: missing beacon node address")
+	}
+	for _, addr := range config.BeaconNode.Addrs {
+		switch {
+		case nodeType == "prysm" || (nodeType == AutoDetect && !IsURL(addr)):
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				add("beacon.addr: expected gRPC host:port, got %q", addr)
+			}
+		case isBeaconNodeType(nodeType):
+			if !IsURL(addr) {
+				add("beacon.addr: expected a URL, got %q", addr)
+			} else if config.BeaconNode.TLSCert != "" {
+				add("beacon.tls-cert: custom TLS certificates are only supported for gRPC connections, not %s", addr)
+			}
+		}
+	}
+	if cert := config.BeaconNode.TLSCert; cert != "" {
+		if _, err := os.Stat(cert); err != nil {
+			add("beacon.tls-cert: %s", err)
+		}
+	}
+	if addr := config.BeaconNode.MetricsAddr; addr != "" && !IsURL(addr) {
+		add("beacon.metrics-addr: expected a URL, got %q", addr)
+	}
+
+	if config.Buffer.Enabled && config.Buffer.Replay != buffer.ReplayOrdered && config.Buffer.Replay != buffer.ReplayLatest {
+		add("buffer.replay: unknown replay mode %q", config.Buffer.Replay)
+	}
+	if info, err := os.Stat(config.DataFolder); err == nil && !info.IsDir() {
+		add("data.folder: %s is not a directory", config.DataFolder)
+	}
+	for _, id := range config.Validators {
+		if err := validateValidatorID(id); err != nil {
+			add("validators: %s", err)
+		}
+	}
+	return problems
+}
+
+func isBeaconNodeType(nodeType string) bool {
+	for _, t := range BeaconNodeTypes {
+		if t == nodeType {
+			return true
+		}
+	}
+	return false
+}
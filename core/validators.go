@@ -0,0 +1,25 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const pubkeyLength = 48
+
+// validateValidatorID checks that a watched validator is given as an index or a pubkey.
+func validateValidatorID(id string) error {
+	if strings.HasPrefix(id, "0x") {
+		key, err := hex.DecodeString(id[2:])
+		if err != nil || len(key) != pubkeyLength {
+			return fmt.Errorf("invalid validator pubkey: %s", id)
+		}
+		return nil
+	}
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return fmt.Errorf("invalid validator index: %s", id)
+	}
+	return nil
+}
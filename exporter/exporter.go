@@ -0,0 +1,364 @@
+package exporter
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/alethio/eth2stats-client/types"
+)
+
+const namespace = "eth2stats_client"
+
+// Registry holds the operational metrics of the client itself.
+var Registry = prometheus.NewRegistry()
+
+var (
+	chainHeadsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chain_heads_sent_total",
+		Help:      "Chain heads sent to the eth2stats server.",
+	}, []string{"node"})
+	chainHeadsRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chain_heads_rate_limited_total",
+		Help:      "Chain heads that were not sent due to rate limiting.",
+	}, []string{"node"})
+	heartbeatsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeats_sent_total",
+		Help:      "Heartbeats sent to the eth2stats server.",
+	}, []string{"node"})
+	heartbeatFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeat_failures_total",
+		Help:      "Heartbeats that could not be sent to the eth2stats server.",
+	}, []string{"node"})
+	reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "Times the client had to set up its connections again after an error.",
+	}, []string{"node"})
+	headSlot = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_slot",
+		Help:      "Slot of the last chain head seen from the beacon node.",
+	}, []string{"node"})
+	headTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_timestamp_seconds",
+		Help:      "Unix time at which the last chain head was seen from the beacon node.",
+	}, []string{"node"})
+	peers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peers",
+		Help:      "Connected peers of the beacon node by direction, \"unknown\" if the node doesn't tell.",
+	}, []string{"node", "direction"})
+	peersByState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peers_by_state",
+		Help:      "Peers listed by the beacon node by connection state.",
+	}, []string{"node", "state"})
+	peersByClient = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peers_by_client",
+		Help:      "Connected peers of the beacon node by client, as told by their agent.",
+	}, []string{"node", "client"})
+	peerConnectsPerMinute = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peer_connects_per_minute",
+		Help:      "Peers that connected per minute, averaged over the churn window.",
+	}, []string{"node"})
+	peerDisconnectsPerMinute = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peer_disconnects_per_minute",
+		Help:      "Peers that disconnected per minute, averaged over the churn window.",
+	}, []string{"node"})
+	peerAverageLifetime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peer_average_lifetime_seconds",
+		Help:      "Mean time peers that disconnected within the churn window were connected.",
+	}, []string{"node"})
+	longLivedPeers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "long_lived_peers",
+		Help:      "Peers connected for at least the long-lived age.",
+	}, []string{"node"})
+	attestationsInPool = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "attestations_in_pool",
+		Help:      "Attestations in the whole pool of the beacon node, for nodes that can tell.",
+	}, []string{"node"})
+	recentAttestationsInPool = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "recent_attestations_in_pool",
+		Help:      "Attestations in the pool of the beacon node for the current and the previous slot only.",
+	}, []string{"node"})
+	epochsSinceFinality = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "epochs_since_finality",
+		Help:      "Epochs since the finalized checkpoint of the last chain head.",
+	}, []string{"node"})
+	epochsSinceJustification = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "epochs_since_justification",
+		Help:      "Epochs since the justified checkpoint of the last chain head.",
+	}, []string{"node"})
+	headLagSlots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_lag_slots",
+		Help:      "Slots the last chain head is behind the current slot.",
+	}, []string{"node"})
+	finalityStalled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "finality_stalled",
+		Help:      "1 if more epochs than the threshold passed since the finalized checkpoint.",
+	}, []string{"node"})
+	headLagging = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_lagging",
+		Help:      "1 if the chain head is more slots than the threshold behind the current slot.",
+	}, []string{"node"})
+	reorgs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorgs_total",
+		Help:      "Chain reorgs seen from the beacon node.",
+	}, []string{"node"})
+	reorgDepth = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reorg_depth_blocks",
+		Help:      "Blocks dropped from the canonical chain by a reorg.",
+		Buckets:   []float64{1, 2, 3, 4, 8, 16, 32, 64},
+	}, []string{"node"})
+	telemetryRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "telemetry_rpc_duration_seconds",
+		Help:      "Latency of the calls made to collect and send telemetry.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"node", "target", "method"})
+	validatorBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "validator_balance_gwei",
+		Help:      "Balance of a watched validator, in Gwei.",
+	}, []string{"node", "validator"})
+	validatorAttestationsMissed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validator_attestations_missed_total",
+		Help:      "Epochs in which the attestation of a watched validator was not included in a block.",
+	}, []string{"node", "validator"})
+	validatorProposalsMissed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validator_proposals_missed_total",
+		Help:      "Blocks a watched validator was scheduled to propose but did not.",
+	}, []string{"node", "validator"})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		chainHeadsSent,
+		chainHeadsRateLimited,
+		heartbeatsSent,
+		heartbeatFailures,
+		reconnects,
+		headSlot,
+		headTimestamp,
+		peers,
+		peersByState,
+		peersByClient,
+		peerConnectsPerMinute,
+		peerDisconnectsPerMinute,
+		peerAverageLifetime,
+		longLivedPeers,
+		attestationsInPool,
+		recentAttestationsInPool,
+		epochsSinceFinality,
+		epochsSinceJustification,
+		headLagSlots,
+		finalityStalled,
+		headLagging,
+		reorgs,
+		reorgDepth,
+		telemetryRPCDuration,
+		validatorBalance,
+		validatorAttestationsMissed,
+		validatorProposalsMissed,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// NodeMetrics are the metrics of a single reported node.
+type NodeMetrics struct {
+	ChainHeadsSent        prometheus.Counter
+	ChainHeadsRateLimited prometheus.Counter
+	HeartbeatsSent        prometheus.Counter
+	HeartbeatFailures     prometheus.Counter
+	Reconnects            prometheus.Counter
+
+	headSlot      prometheus.Gauge
+	headTimestamp prometheus.Gauge
+	peers         *prometheus.GaugeVec
+	peersByState  *prometheus.GaugeVec
+	peersByClient *prometheus.GaugeVec
+	// label values set before, to zero the ones that are gone
+	peerStates  map[string]bool
+	peerClients map[string]bool
+
+	peerConnectsPerMinute    prometheus.Gauge
+	peerDisconnectsPerMinute prometheus.Gauge
+	peerAverageLifetime      prometheus.Gauge
+	longLivedPeers           prometheus.Gauge
+
+	// only set for nodes that count their pool that way, so not created up front
+	attestationsInPool       *prometheus.GaugeVec
+	recentAttestationsInPool *prometheus.GaugeVec
+
+	epochsSinceFinality      prometheus.Gauge
+	epochsSinceJustification prometheus.Gauge
+	headLagSlots             prometheus.Gauge
+	finalityStalled          prometheus.Gauge
+	headLagging              prometheus.Gauge
+	reorgs                   prometheus.Counter
+	reorgDepth               prometheus.Observer
+	telemetryRPCDuration     prometheus.ObserverVec
+
+	validatorBalance            *prometheus.GaugeVec
+	validatorAttestationsMissed *prometheus.CounterVec
+	validatorProposalsMissed    *prometheus.CounterVec
+}
+
+func ForNode(name string) *NodeMetrics {
+	labels := prometheus.Labels{"node": name}
+	return &NodeMetrics{
+		ChainHeadsSent:           chainHeadsSent.With(labels),
+		ChainHeadsRateLimited:    chainHeadsRateLimited.With(labels),
+		HeartbeatsSent:           heartbeatsSent.With(labels),
+		HeartbeatFailures:        heartbeatFailures.With(labels),
+		Reconnects:               reconnects.With(labels),
+		headSlot:                 headSlot.With(labels),
+		headTimestamp:            headTimestamp.With(labels),
+		peers:                    peers.MustCurryWith(labels),
+		peersByState:             peersByState.MustCurryWith(labels),
+		peersByClient:            peersByClient.MustCurryWith(labels),
+		peerStates:               make(map[string]bool),
+		peerClients:              make(map[string]bool),
+		peerConnectsPerMinute:    peerConnectsPerMinute.With(labels),
+		peerDisconnectsPerMinute: peerDisconnectsPerMinute.With(labels),
+		peerAverageLifetime:      peerAverageLifetime.With(labels),
+		longLivedPeers:           longLivedPeers.With(labels),
+		attestationsInPool:       attestationsInPool.MustCurryWith(labels),
+		recentAttestationsInPool: recentAttestationsInPool.MustCurryWith(labels),
+		epochsSinceFinality:      epochsSinceFinality.With(labels),
+		epochsSinceJustification: epochsSinceJustification.With(labels),
+		headLagSlots:             headLagSlots.With(labels),
+		finalityStalled:          finalityStalled.With(labels),
+		headLagging:              headLagging.With(labels),
+		reorgs:                   reorgs.With(labels),
+		reorgDepth:               reorgDepth.With(labels),
+		telemetryRPCDuration:     telemetryRPCDuration.MustCurryWith(labels),
+
+		validatorBalance:            validatorBalance.MustCurryWith(labels),
+		validatorAttestationsMissed: validatorAttestationsMissed.MustCurryWith(labels),
+		validatorProposalsMissed:    validatorProposalsMissed.MustCurryWith(labels),
+	}
+}
+
+// SeenHead records the slot of a new chain head and when it was seen.
+func (m *NodeMetrics) SeenHead(slot uint64) {
+	m.headSlot.Set(float64(slot))
+	m.headTimestamp.SetToCurrentTime()
+}
+
+// SeenPeers records the peers of the beacon node by direction, state and client.
+func (m *NodeMetrics) SeenPeers(stats types.PeerStats) {
+	m.peers.With(prometheus.Labels{"direction": "inbound"}).Set(float64(stats.Inbound))
+	m.peers.With(prometheus.Labels{"direction": "outbound"}).Set(float64(stats.Outbound))
+	m.peers.With(prometheus.Labels{"direction": "unknown"}).Set(float64(stats.Connected - stats.Inbound - stats.Outbound))
+	setCounts(m.peersByState, "state", m.peerStates, stats.States)
+	setCounts(m.peersByClient, "client", m.peerClients, stats.Clients)
+	if stats.Churn != nil {
+		m.peerConnectsPerMinute.Set(stats.Churn.ConnectsPerMinute)
+		m.peerDisconnectsPerMinute.Set(stats.Churn.DisconnectsPerMinute)
+		m.peerAverageLifetime.Set(stats.Churn.AverageLifetime)
+		m.longLivedPeers.Set(float64(stats.Churn.LongLived))
+	}
+}
+
+// setCounts sets a gauge per label value, zeroing the ones seen before that are gone now.
+func setCounts(vec *prometheus.GaugeVec, label string, seen map[string]bool, counts map[string]int64) {
+	for value := range seen {
+		if _, ok := counts[value]; !ok {
+			vec.With(prometheus.Labels{label: value}).Set(0)
+		}
+	}
+	for value, count := range counts {
+		seen[value] = true
+		vec.With(prometheus.Labels{label: value}).Set(float64(count))
+	}
+}
+
+// SeenAttestationsInPool records the size of the whole attestation pool of the beacon node.
+func (m *NodeMetrics) SeenAttestationsInPool(attestations int64) {
+	m.attestationsInPool.With(nil).Set(float64(attestations))
+}
+
+// SeenRecentAttestationsInPool records the attestations in pool of the last few slots only.
+func (m *NodeMetrics) SeenRecentAttestationsInPool(attestations int64) {
+	m.recentAttestationsInPool.With(nil).Set(float64(attestations))
+}
+
+// SeenFinality records the finality derived from the last chain head.
+func (m *NodeMetrics) SeenFinality(f types.Finality) {
+	m.epochsSinceFinality.Set(float64(f.EpochsSinceFinality))
+	m.epochsSinceJustification.Set(float64(f.EpochsSinceJustification))
+	m.headLagSlots.Set(float64(f.HeadLagSlots))
+	m.finalityStalled.Set(boolValue(f.FinalityStalled))
+	m.headLagging.Set(boolValue(f.HeadLagging))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// SeenReorg counts a reorg and how many blocks it dropped.
+func (m *NodeMetrics) SeenReorg(depth int) {
+	m.reorgs.Inc()
+	m.reorgDepth.Observe(float64(depth))
+}
+
+// TimeRPC starts timing a call; the returned function records its duration.
+func (m *NodeMetrics) TimeRPC(target, method string) func() {
+	start := time.Now()
+	return func() {
+		m.telemetryRPCDuration.With(prometheus.Labels{"target": target, "method": method}).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SeenValidator records the balance of a watched validator.
+func (m *NodeMetrics) SeenValidator(v types.Validator) {
+	m.validatorBalance.With(validatorLabels(v.Index)).Set(float64(v.Balance))
+}
+
+// SeenValidatorPerformance counts the duties a watched validator missed in an epoch.
+func (m *NodeMetrics) SeenValidatorPerformance(p types.ValidatorPerformance) {
+	labels := validatorLabels(p.Index)
+	if !p.AttestationIncluded {
+		m.validatorAttestationsMissed.With(labels).Inc()
+	}
+	m.validatorProposalsMissed.With(labels).Add(float64(p.ProposalsMissed))
+}
+
+func validatorLabels(index uint64) prometheus.Labels {
+	return prometheus.Labels{"validator": strconv.FormatUint(index, 10)}
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"fmt"
+
+	formatter "github.com/kwix/logrus-module-formatter"
+	"github.com/sirupsen/logrus"
+)
+
+// ModuleFormatter filters entries by the level configured for their module, like
+// logrus-module-formatter does, but can keep the module as a field for structured formats.
+type ModuleFormatter struct {
+	levels    formatter.ModulesMap
+	formatter logrus.Formatter
+	// moduleField keeps the module as a field instead of prefixing the message with it.
+	moduleField bool
+	// skip lists the modules that are logged elsewhere.
+	skip map[string]bool
+}
+
+func (f *ModuleFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	module, hasModule := entry.Data["module"].(string)
+	if hasModule && f.skip[module] {
+		return nil, nil
+	}
+	if !f.enabled(module, hasModule, entry.Level) {
+		return nil, nil
+	}
+	if !hasModule || f.moduleField {
+		return f.formatter.Format(entry)
+	}
+
+	// the entry is shared with the hooks, so it is left untouched
+	e := *entry
+	e.Data = make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		if k != "module" {
+			e.Data[k] = v
+		}
+	}
+	e.Message = fmt.Sprintf("[%s] %s", module, entry.Message)
+	return f.formatter.Format(&e)
+}
+
+func (f *ModuleFormatter) enabled(module string, hasModule bool, level logrus.Level) bool {
+	if len(f.levels) == 0 {
+		return true
+	}
+	if hasModule {
+		if moduleLevel, ok := f.levels[module]; ok {
+			return level <= moduleLevel
+		}
+	}
+	globalLevel, ok := f.levels["*"]
+	return ok && level <= globalLevel
+}
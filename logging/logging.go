@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	formatter "github.com/kwix/logrus-module-formatter"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+type Config struct {
+	// Levels per module, as parsed from the "module=level,module=level" syntax.
+	Levels         formatter.ModulesMap
+	Format         string
+	FullTimestamps bool
+	// Files routes the logs of a module to a file instead of the standard output.
+	Files map[string]string
+	// MaxSize is the size in bytes at which a log file is rotated; 0 disables rotation.
+	MaxSize    int64
+	MaxBackups int
+}
+
+// ParseFiles parses the "module=path,module=path" syntax of file sinks.
+func ParseFiles(files string) (map[string]string, error) {
+	sinks := make(map[string]string)
+	if files == "" {
+		return sinks, nil
+	}
+	for _, f := range strings.Split(files, ",") {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid log file %q, expected module=path", f)
+		}
+		if _, exists := sinks[parts[0]]; exists {
+			return nil, fmt.Errorf("module %q is routed to several log files", parts[0])
+		}
+		sinks[parts[0]] = parts[1]
+	}
+	return sinks, nil
+}
+
+// Setup configures the standard logger: the format of its output and the modules routed to files.
+func Setup(config Config) error {
+	newFormatter, err := formatterFor(config.Format, config.FullTimestamps)
+	if err != nil {
+		return err
+	}
+
+	skip := make(map[string]bool)
+	for module, path := range config.Files {
+		file, err := OpenRotatingFile(path, config.MaxSize, config.MaxBackups)
+		if err != nil {
+			return fmt.Errorf("opening log file of module %s: %s", module, err)
+		}
+		skip[module] = true
+
+		// files are never interactive consoles
+		fileFormatter, _ := formatterFor(config.Format, true)
+		if text, ok := fileFormatter.(*logrus.TextFormatter); ok {
+			text.DisableColors = true
+		}
+		logrus.AddHook(&fileHook{
+			module: module,
+			file:   file,
+			formatter: &ModuleFormatter{
+				levels:      config.Levels,
+				formatter:   fileFormatter,
+				moduleField: config.Format != FormatText,
+			},
+		})
+	}
+
+	// entries are filtered per module by the formatters
+	logrus.SetLevel(logrus.TraceLevel)
+	logrus.SetFormatter(&ModuleFormatter{
+		levels:      config.Levels,
+		formatter:   newFormatter,
+		moduleField: config.Format != FormatText,
+		skip:        skip,
+	})
+	return nil
+}
+
+func formatterFor(format string, fullTimestamps bool) (logrus.Formatter, error) {
+	switch format {
+	case FormatText:
+		return &logrus.TextFormatter{
+			FullTimestamp: fullTimestamps,
+		}, nil
+	case FormatLogfmt:
+		return &logrus.TextFormatter{
+			DisableColors: true,
+			FullTimestamp: true,
+		}, nil
+	case FormatJSON:
+		return &logrus.JSONFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+}
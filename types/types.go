@@ -1,10 +1,114 @@
 package types
 
+import (
+	"time"
+)
+
 type ChainHead struct {
-	HeadSlot           uint64
-	HeadBlockRoot      string
-	FinalizedSlot      uint64
-	FinalizedBlockRoot string
-	JustifiedSlot      uint64
-	JustifiedBlockRoot string
+	HeadSlot           uint64 `json:"headSlot"`
+	HeadBlockRoot      string `json:"headBlockRoot"`
+	FinalizedSlot      uint64 `json:"finalizedSlot"`
+	FinalizedBlockRoot string `json:"finalizedBlockRoot"`
+	JustifiedSlot      uint64 `json:"justifiedSlot"`
+	JustifiedBlockRoot string `json:"justifiedBlockRoot"`
+	// ParentRoot is the root of the parent of the head block, empty if the node doesn't tell.
+	ParentRoot string `json:"parentRoot,omitempty"`
+}
+
+// Peer is a peer of the beacon node; fields the node doesn't tell are empty.
+type Peer struct {
+	ID string `json:"id"`
+	// Direction is "inbound" or "outbound".
+	Direction string `json:"direction,omitempty"`
+	// State as named by the standard API: "connected", "connecting", "disconnected" or "disconnecting".
+	State   string `json:"state,omitempty"`
+	Address string `json:"address,omitempty"`
+	// Agent is the libp2p agent version the peer announced, e.g. "Lighthouse/v1.0.0/x86_64-linux".
+	Agent string `json:"agent,omitempty"`
+}
+
+// PeerStats aggregates the peers of a beacon node.
+type PeerStats struct {
+	// Connected, Inbound and Outbound count connected peers only.
+	Connected int64 `json:"connected"`
+	Inbound   int64 `json:"inbound"`
+	Outbound  int64 `json:"outbound"`
+	// States counts every listed peer by state.
+	States map[string]int64 `json:"states"`
+	// Clients counts the connected peers by client, as told by their agent.
+	Clients map[string]int64 `json:"clients"`
+	// Churn is derived from successive peer lists, once there are two of them.
+	Churn *PeerChurn `json:"churn,omitempty"`
+}
+
+// PeerChurn tells how often the connected peers of a beacon node change.
+type PeerChurn struct {
+	// ConnectsPerMinute and DisconnectsPerMinute are averaged over the churn window.
+	ConnectsPerMinute    float64 `json:"connectsPerMinute"`
+	DisconnectsPerMinute float64 `json:"disconnectsPerMinute"`
+	// AverageLifetime is the mean time in seconds peers that disconnected within the window were connected,
+	// counting only the ones whose connect was seen; 0 if there are none.
+	AverageLifetime float64 `json:"averageLifetime"`
+	// LongLived counts the peers connected for at least the long-lived age.
+	LongLived int64 `json:"longLived"`
+}
+
+// Finality tells how far the chain head and its checkpoints are behind the wall clock.
+type Finality struct {
+	CurrentSlot              uint64 `json:"currentSlot"`
+	EpochsSinceFinality      uint64 `json:"epochsSinceFinality"`
+	EpochsSinceJustification uint64 `json:"epochsSinceJustification"`
+	// HeadLagSlots is how many slots the head is behind the current slot.
+	HeadLagSlots uint64 `json:"headLagSlots"`
+	// HeadAge is the time in seconds since the start of the head slot.
+	HeadAge float64 `json:"headAge"`
+	// FinalityStalled and HeadLagging tell whether the thresholds of the monitor were exceeded.
+	FinalityStalled bool `json:"finalityStalled"`
+	HeadLagging     bool `json:"headLagging"`
+}
+
+// Reorg is a change of the chain head to a block that does not descend from the previous head.
+type Reorg struct {
+	Time        time.Time `json:"time"`
+	OldHeadSlot uint64    `json:"oldHeadSlot"`
+	OldHeadRoot string    `json:"oldHeadRoot"`
+	NewHeadSlot uint64    `json:"newHeadSlot"`
+	NewHeadRoot string    `json:"newHeadRoot"`
+	// CommonAncestor is the root of the latest block both heads descend from, if it was seen.
+	CommonAncestor string `json:"commonAncestor,omitempty"`
+	// Depth is the number of blocks dropped from the canonical chain.
+	Depth int `json:"depth"`
+	// Dropped are the roots of the dropped blocks that were seen as heads, latest first.
+	Dropped []string `json:"dropped"`
+	// Estimated tells that the common ancestor wasn't seen, so Depth only counts the dropped heads that were.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// Spec holds the chain configuration values the client needs to tell slots and epochs apart.
+type Spec struct {
+	ConfigName     string `json:"configName"`
+	SecondsPerSlot uint64 `json:"secondsPerSlot"`
+	SlotsPerEpoch  uint64 `json:"slotsPerEpoch"`
+}
+
+// Validator is the current state of a validator.
+type Validator struct {
+	Index  uint64 `json:"index"`
+	Pubkey string `json:"pubkey"`
+	// Status as named by the standard API, e.g. "active_ongoing".
+	Status string `json:"status"`
+	// Balance in Gwei.
+	Balance uint64 `json:"balance"`
+}
+
+// ValidatorPerformance tells how a validator carried out its duties in an epoch.
+type ValidatorPerformance struct {
+	Index uint64 `json:"index"`
+	Epoch uint64 `json:"epoch"`
+	// AttestationIncluded tells whether the attestation of the validator made it into a block,
+	// InclusionDelay slots after the slot it was due in.
+	AttestationIncluded bool   `json:"attestationIncluded"`
+	InclusionDelay      uint64 `json:"inclusionDelay,omitempty"`
+	ProposalsScheduled  int    `json:"proposalsScheduled"`
+	ProposalsMissed     int    `json:"proposalsMissed"`
 }
@@ -4,11 +4,11 @@ import (
 	"time"
 )
 
-const PollingInterval = 30 * time.Second
-
-const PollRetryAttempts = 4
-
-const PollTimeout = 5 * time.Second
-
-const PollDialTimeout = 10 * time.Second
-const PollTLSTimeout = 10 * time.Second
+// Defaults for the zero values of Config.
+const (
+	PollingInterval   = 30 * time.Second
+	PollRetryAttempts = 4
+	PollTimeout       = 5 * time.Second
+	// PollDialTimeout applies to both connecting and the TLS handshake.
+	PollDialTimeout = 10 * time.Second
+)
@@ -17,8 +17,11 @@ import (
 var log = logrus.WithField("module", "metrics-watcher")
 
 type Config struct {
-	MetricsURL   string
-	PollInterval time.Duration
+	MetricsURL    string
+	PollInterval  time.Duration
+	PollTimeout   time.Duration
+	DialTimeout   time.Duration
+	RetryAttempts uint
 }
 
 type Watcher struct {
@@ -32,16 +35,29 @@ type Watcher struct {
 }
 
 func New(config Config) *Watcher {
+	if config.PollInterval == 0 {
+		config.PollInterval = PollingInterval
+	}
+	if config.PollTimeout == 0 {
+		config.PollTimeout = PollTimeout
+	}
+	if config.DialTimeout == 0 {
+		config.DialTimeout = PollDialTimeout
+	}
+	if config.RetryAttempts == 0 {
+		config.RetryAttempts = PollRetryAttempts
+	}
+
 	var netTransport = &http.Transport{
 		DialContext: (&net.Dialer{
-			Timeout: PollDialTimeout,
+			Timeout: config.DialTimeout,
 		}).DialContext,
 		// make keep-alives longer than the interval to make client re-use effective.
-		IdleConnTimeout:     2 * PollingInterval,
-		TLSHandshakeTimeout: PollTLSTimeout,
+		IdleConnTimeout:     2 * config.PollInterval,
+		TLSHandshakeTimeout: config.DialTimeout,
 	}
 	var httpClient = &http.Client{
-		Timeout:   PollTimeout,
+		Timeout:   config.PollTimeout,
 		Transport: netTransport,
 	}
 	return &Watcher{
@@ -52,12 +68,12 @@ func New(config Config) *Watcher {
 
 func (w *Watcher) Run(ctx context.Context) {
 	log.Info("Started polling metrics")
-	w.poll()
-	ticker := time.NewTicker(PollingInterval)
+	w.poll(ctx)
+	ticker := time.NewTicker(w.config.PollInterval)
 	for {
 		select {
 		case <-ticker.C:
-			w.poll()
+			w.poll(ctx)
 			break
 		case <-ctx.Done():
 			ticker.Stop()
@@ -67,11 +83,11 @@ func (w *Watcher) Run(ctx context.Context) {
 	}
 }
 
-func (w *Watcher) poll() {
+func (w *Watcher) poll(ctx context.Context) {
 	_ = retry.Do(
 		func() error {
 			log.Info("querying metrics")
-			metrics, err := w.query()
+			metrics, err := w.query(ctx)
 			if err != nil {
 				log.Warnf("failed to poll metrics: %s", err)
 				return err
@@ -79,22 +95,37 @@ func (w *Watcher) poll() {
 			w.monitorMetrics(metrics)
 			return nil
 		},
-		retry.Attempts(PollRetryAttempts),
+		retry.Attempts(w.config.RetryAttempts),
+		retry.RetryIf(func(err error) bool {
+			return ctx.Err() == nil
+		}),
 	)
 }
 
-func (w *Watcher) query() (map[string]*io_prometheus_client.MetricFamily, error) {
+// Scrape queries the metrics once, without retrying, and returns the memory usage found in them, if any.
+func (w *Watcher) Scrape(ctx context.Context) (*int64, error) {
+	metrics, err := w.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w.monitorMetrics(metrics)
+	return w.GetMemUsage(), nil
+}
+
+func (w *Watcher) query(ctx context.Context) (map[string]*io_prometheus_client.MetricFamily, error) {
 	// Don't keep a request open for longer than the interval time.
 	req, err := http.NewRequest("GET", w.config.MetricsURL, nil)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 	// disable caching for up-to-date metrics (if running behind a proxy or something else)s
 	req.Header.Set("Cache-control", "no-cache")
 	resp, err := w.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	log.Trace("done querying metrics")
 
 	if resp.StatusCode != http.StatusOK {